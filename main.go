@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"flag"
+	"fmt"
 	"io/fs"
 	"log/slog"
 	"net/http"
@@ -16,6 +19,8 @@ import (
 	"github.com/sodefrin/PP/server/api"
 	"github.com/sodefrin/PP/server/db"
 	"github.com/sodefrin/PP/server/lib"
+	"github.com/sodefrin/PP/server/matchmaking"
+	"github.com/sodefrin/PP/server/ws"
 
 	_ "modernc.org/sqlite"
 )
@@ -23,16 +28,13 @@ import (
 //go:embed public/*
 var content embed.FS
 
-//go:embed server/db/schema.sql
-var schema string
-
 var queries *db.Queries
 var dbConn *sql.DB
 
-func initDB(ctx context.Context) {
+func initDB(ctx context.Context, path string) {
 	var err error
 	// Use otelsql to open database with tracing
-	dbConn, err = otelsql.Open("sqlite", "file::memory:?cache=shared",
+	dbConn, err = otelsql.Open("sqlite", db.DSN(path),
 		otelsql.WithAttributes(semconv.DBSystemSqlite),
 		otelsql.WithSQLCommenter(true),
 	)
@@ -46,15 +48,14 @@ func initDB(ctx context.Context) {
 		slog.ErrorContext(ctx, "Failed to register DB stats metrics", "error", err)
 	}
 
-	// Execute schema
-	if _, err := dbConn.Exec(schema); err != nil {
-		slog.ErrorContext(ctx, "Failed to execute schema", "error", err)
+	if err := db.Migrate(ctx, dbConn); err != nil {
+		slog.ErrorContext(ctx, "Failed to apply migrations", "error", err)
 		os.Exit(1)
 	}
 
 	queries = db.New(dbConn)
 
-	slog.InfoContext(ctx, "Database initialized (in-memory)")
+	slog.InfoContext(ctx, "Database initialized", "path", path)
 }
 
 func main() {
@@ -63,13 +64,34 @@ func main() {
 	slog.SetDefault(logger)
 
 	ctx := context.Background()
-	initDB(ctx)
+
+	cfg, err := lib.LoadConfig()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load config", "error", err)
+		os.Exit(1)
+	}
+	if cfg.DevMode {
+		slog.WarnContext(ctx, "Running in dev mode: websocket origins are not checked")
+	}
+
+	initDB(ctx, cfg.DBPath)
 	defer func() {
 		if err := dbConn.Close(); err != nil {
 			slog.ErrorContext(ctx, "Failed to close database", "error", err)
 		}
 	}()
 
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			_ = dbConn.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	go lib.SweepSessions(ctx, queries, cfg.SessionSweepInterval)
+
 	// Serve static files from embedded filesystem
 	publicFS, err := fs.Sub(content, "public")
 	if err != nil {
@@ -77,20 +99,53 @@ func main() {
 		os.Exit(1)
 	}
 
+	hub := ws.NewHub(dbConn, queries, cfg.Session, ws.Options{
+		SpectatorDelay: cfg.SpectatorDelay,
+		ReconnectGrace: cfg.ReconnectGrace,
+		TimeLimits:     cfg.TimeLimits,
+	})
+	if err := hub.ResumeBots(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to resume bot rooms", "error", err)
+	}
+
+	queue := matchmaking.NewQueue(matchmaking.DefaultWindow)
+	matcher := matchmaking.NewMatcher(queue, dbConn, queries, func(_ context.Context, room db.Room) {
+		hub.NotifyMatch(room)
+	})
+	go matcher.Run(ctx, matchmaking.Interval)
+
 	mux := lib.NewServeMux()
 	mux.Handle("/", http.FileServer(http.FS(publicFS)))
 
-	mux.HandleFunc("/api/health", api.HealthHandler())
-	mux.HandleFunc("/ws", api.WsHandler())
-	mux.HandleFunc("/api/signup", api.SignupHandler(queries))
-	mux.HandleFunc("/api/signin", api.SigninHandler(queries))
-	mux.HandleFunc("/api/me", lib.RequireAuthMiddleware(api.MeHandler()))
+	checkOrigin := lib.NewOriginChecker(cfg.AllowedOrigins, cfg.DevMode)
+	mux.Get("/ws", api.WsHandler(hub, checkOrigin), lib.RequireAuthMiddleware)
+
+	public := mux.Group("/api")
+	public.Get("/health", api.HealthHandler())
+	public.Post("/signup", api.SignupHandler(queries, cfg.Session, cfg.Password))
+	public.Post("/signin", api.SigninHandler(queries, cfg.Session))
+	public.Post("/signout", api.SignoutHandler(queries))
+	public.Post("/password/reset", api.ResetPasswordHandler(dbConn, queries, cfg.Password))
+
+	authed := mux.Group("/api", lib.RequireAuthMiddleware)
+	authed.Post("/signout/all", api.SignoutAllHandler(queries))
+	authed.Get("/me", api.MeHandler())
+	authed.Post("/me/password", api.ChangePasswordHandler(dbConn, queries, cfg.Password))
+	authed.Get("/rooms", api.ListRoomsHandler(queries, hub))
+	authed.Post("/rooms", api.CreateRoomHandler(dbConn, queries, hub))
+	authed.Post("/rooms/{id}/join", api.JoinRoomHandler(queries))
+	authed.Post("/rooms/{id}/leave", api.LeaveRoomHandler(queries, hub))
+	authed.Get("/users/{id}/matches", api.UserMatchesHandler(queries))
+	authed.Get("/matches/{id}/replay", api.MatchReplayHandler(queries))
+	authed.Get("/leaderboard", api.LeaderboardHandler(queries))
+	authed.Post("/matchmaking/queue", api.EnqueueHandler(queue, queries))
+	authed.Delete("/matchmaking/queue", api.DequeueHandler(queue))
 
 	// Wrap with Logging Middleware
 	handler := lib.LoggingMiddleware(mux)
 
 	// Wrap with Auth Middleware
-	handler = lib.AuthMiddleware(queries)(handler)
+	handler = lib.AuthMiddleware(queries, cfg.Session)(handler)
 
 	// Wrap with OpenTelemetry
 	handler = otelhttp.NewHandler(handler, "server")
@@ -114,3 +169,51 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runCommand runs an admin command against the database instead of
+// starting the server:
+//
+//	server reset-password [-ttl 24h] NAME
+func runCommand(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "reset-password":
+		return resetPasswordCommand(ctx, args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// resetPasswordCommand prints a one-time token that lets the named user
+// choose a new password, for users who have forgotten theirs.
+func resetPasswordCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	ttl := flags.Duration("ttl", lib.DefaultResetTTL, "how long the token stays valid")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: reset-password [-ttl 24h] NAME")
+	}
+	if *ttl <= 0 {
+		return errors.New("-ttl must be positive")
+	}
+
+	name := flags.Arg(0)
+	user, err := queries.GetUserByName(ctx, name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user named %q", name)
+	}
+	if err != nil {
+		return err
+	}
+	if user.Bot {
+		return fmt.Errorf("%q is a bot and has no password", name)
+	}
+
+	token, err := lib.CreatePasswordReset(ctx, dbConn, queries, user.ID, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Reset token for %s, valid for %s. Send them /#reset=<token> on this server.\n", user.Name, *ttl)
+	fmt.Println(token)
+	return nil
+}
@@ -723,6 +723,9 @@ window.onload = async () => {
     const signupBtn = document.getElementById('signup-btn');
     signupBtn.addEventListener('click', handleSignup);
 
+    const logoutBtn = document.getElementById('logout-btn');
+    logoutBtn.addEventListener('click', handleLogout);
+
     document.getElementById('to-signup').addEventListener('click', (e) => {
         e.preventDefault();
         document.getElementById('login-container').style.display = 'none';
@@ -735,6 +738,16 @@ window.onload = async () => {
         document.getElementById('login-container').style.display = 'block';
     });
 
+    document.getElementById('reset-btn').addEventListener('click', handleReset);
+
+    // Reset links from the reset-password command carry the token in the
+    // fragment, which is never sent to the server.
+    if (window.location.hash.startsWith('#reset=')) {
+        document.getElementById('login-container').style.display = 'none';
+        document.getElementById('reset-container').style.display = 'block';
+        return;
+    }
+
     // Check if user is already logged in
     try {
         const response = await fetch('/api/me');
@@ -751,6 +764,17 @@ window.onload = async () => {
     }
 };
 
+// apiError reads the JSON error body the server sends with failed
+// requests: { error: { code, message, fields, trace_id } }.
+async function apiError(response) {
+    try {
+        const data = await response.json();
+        return data.error || {};
+    } catch (error) {
+        return {};
+    }
+}
+
 async function performLogin(name, password, errorDiv) {
     try {
         const response = await fetch('/api/signin', {
@@ -834,13 +858,68 @@ async function performSignup(name, password, errorDiv) {
             new Game();
             return true;
         } else {
-            // Login failed
-            if (errorDiv) errorDiv.innerText = 'Login failed: Invalid credentials';
+            const error = await apiError(response);
+            if (errorDiv) {
+                const field = (error.fields || [])[0];
+                if (error.code === 'name_taken') {
+                    errorDiv.innerText = 'Signup failed: that name is already taken';
+                } else if (field) {
+                    errorDiv.innerText = 'Signup failed: ' + field.field + ' ' + field.message;
+                } else {
+                    errorDiv.innerText = 'Signup failed: ' + (error.message || response.statusText);
+                }
+            }
             return false;
         }
     } catch (error) {
-        console.error('Login error:', error);
-        if (errorDiv) errorDiv.innerText = 'Login error';
+        console.error('Signup error:', error);
+        if (errorDiv) errorDiv.innerText = 'Signup error';
         return false;
     }
-}
\ No newline at end of file
+}
+
+async function handleReset() {
+    const password = document.getElementById('reset-password').value;
+    const errorDiv = document.getElementById('reset-error');
+    const token = window.location.hash.slice('#reset='.length);
+
+    if (!password) {
+        errorDiv.innerText = 'Please enter a new password';
+        return;
+    }
+
+    try {
+        const response = await fetch('/api/password/reset', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ token, password })
+        });
+
+        if (response.ok) {
+            history.replaceState(null, '', window.location.pathname);
+            document.getElementById('reset-container').style.display = 'none';
+            document.getElementById('login-container').style.display = 'block';
+            document.getElementById('login-error').innerText = 'Password changed, please log in';
+        } else {
+            const error = await apiError(response);
+            const field = (error.fields || [])[0];
+            errorDiv.innerText = 'Reset failed: ' +
+                (field ? field.field + ' ' + field.message : (error.message || response.statusText));
+        }
+    } catch (error) {
+        console.error('Reset error:', error);
+        errorDiv.innerText = 'Reset error';
+    }
+}
+
+async function handleLogout() {
+    try {
+        await fetch('/api/signout', { method: 'POST' });
+    } catch (error) {
+        console.error('Logout error:', error);
+    }
+    // Reload so the running Game and its key handlers are torn down.
+    window.location.reload();
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// LeaderboardHandler lists users by rating. Players with equal ratings
+// share a rank.
+func LeaderboardHandler(queries *db.Queries) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+		limit, offset, ok := pageFromQuery(r)
+		if !ok {
+			return lib.BadRequest("Invalid limit or offset")
+		}
+
+		users, err := queries.ListLeaderboard(r.Context(), db.ListLeaderboardParams{
+			Limit:  limit,
+			Offset: offset,
+		})
+		if err != nil {
+			return err
+		}
+		total, err := queries.CountUsers(r.Context())
+		if err != nil {
+			return err
+		}
+		myRank, err := queries.GetRatingRank(r.Context(), user.Rating)
+		if err != nil {
+			return err
+		}
+
+		resp := dto.Leaderboard{
+			Entries: make([]dto.LeaderboardEntry, 0, len(users)),
+			Total:   total,
+			Limit:   limit,
+			Offset:  offset,
+			Me:      toLeaderboardEntry(user, myRank),
+		}
+		for i, u := range users {
+			var rank int64
+			switch {
+			case i > 0 && u.Rating == users[i-1].Rating:
+				rank = resp.Entries[i-1].Rank
+			case i == 0 && offset > 0:
+				// A tie may continue from the previous page.
+				if rank, err = queries.GetRatingRank(r.Context(), u.Rating); err != nil {
+					return err
+				}
+			default:
+				rank = offset + int64(i) + 1
+			}
+			resp.Entries = append(resp.Entries, toLeaderboardEntry(u, rank))
+		}
+
+		return lib.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func toLeaderboardEntry(u db.User, rank int64) dto.LeaderboardEntry {
+	return dto.LeaderboardEntry{
+		Rank:   rank,
+		UserID: u.ID,
+		Name:   u.Name,
+		Rating: u.Rating,
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+)
+
+func createRatedUser(t *testing.T, name string, rating int64) db.User {
+	t.Helper()
+	user := createTestUser(t, name)
+	if err := testQueries.UpdateUserRating(context.Background(), db.UpdateUserRatingParams{
+		Rating: rating,
+		ID:     user.ID,
+	}); err != nil {
+		t.Fatalf("UpdateUserRating error: %v", err)
+	}
+	user.Rating = rating
+	return user
+}
+
+func getLeaderboard(t *testing.T, user db.User, query string) (int, dto.Leaderboard) {
+	t.Helper()
+	req := newAuthedRequest(http.MethodGet, "/api/leaderboard"+query, nil, user)
+	w := httptest.NewRecorder()
+	LeaderboardHandler(testQueries).ServeHTTP(w, req)
+	var resp dto.Leaderboard
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestLeaderboardHandler(t *testing.T) {
+	top := createRatedUser(t, "leadertop", 9000)
+	tieA := createRatedUser(t, "leadertiea", 8000)
+	tieB := createRatedUser(t, "leadertieb", 8000)
+	me := createTestUser(t, "leaderme")
+
+	bot, err := testQueries.CreateBotUser(context.Background(), "leaderbot")
+	if err != nil {
+		t.Fatalf("CreateBotUser error: %v", err)
+	}
+	if err := testQueries.UpdateUserRating(context.Background(), db.UpdateUserRatingParams{
+		Rating: 9500,
+		ID:     bot.ID,
+	}); err != nil {
+		t.Fatalf("UpdateUserRating error: %v", err)
+	}
+
+	t.Run("FirstPage", func(t *testing.T) {
+		code, resp := getLeaderboard(t, me, "?limit=2")
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", code)
+		}
+		if len(resp.Entries) != 2 || resp.Limit != 2 || resp.Total < 4 {
+			t.Fatalf("unexpected page: %+v", resp)
+		}
+		// The bot is rated higher but is not listed.
+		if e := resp.Entries[0]; e.UserID != top.ID || e.Rank != 1 || e.Rating != 9000 {
+			t.Errorf("unexpected first entry: %+v", e)
+		}
+		if e := resp.Entries[1]; e.UserID != tieA.ID || e.Rank != 2 {
+			t.Errorf("unexpected second entry: %+v", e)
+		}
+	})
+
+	t.Run("TieAcrossPages", func(t *testing.T) {
+		_, resp := getLeaderboard(t, me, "?limit=1&offset=2")
+		if len(resp.Entries) != 1 {
+			t.Fatalf("expected 1 entry, got %d", len(resp.Entries))
+		}
+		if e := resp.Entries[0]; e.UserID != tieB.ID || e.Rank != 2 {
+			t.Errorf("expected %d to share rank 2, got %+v", tieB.ID, e)
+		}
+	})
+
+	t.Run("OwnRank", func(t *testing.T) {
+		_, resp := getLeaderboard(t, me, "?limit=1")
+		if resp.Me.UserID != me.ID || resp.Me.Rank != 4 || resp.Me.Rating != me.Rating {
+			t.Errorf("expected own rank 4, got %+v", resp.Me)
+		}
+	})
+
+	t.Run("InvalidPage", func(t *testing.T) {
+		if code, _ := getLeaderboard(t, me, "?offset=x"); code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// MatchReplayHandler serves the seed and recorded inputs of a finished
+// match in the dto.Replay format, as a download.
+func MatchReplayHandler(queries *db.Queries) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		matchID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil || matchID <= 0 {
+			return lib.BadRequest("Invalid match id")
+		}
+
+		match, err := queries.GetMatch(r.Context(), matchID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return lib.NotFound("Match not found")
+			}
+			return err
+		}
+		room, err := queries.GetRoom(r.Context(), match.RoomID)
+		if err != nil {
+			return err
+		}
+		events, err := queries.ListMatchEvents(r.Context(), match.RoomID)
+		if err != nil {
+			return err
+		}
+
+		resp := dto.Replay{
+			Version:     dto.ReplayVersion,
+			MatchID:     match.ID,
+			Seed:        strconv.FormatInt(match.Seed, 10),
+			SharedQueue: room.SharedQueue,
+			Players:     [2]int64{match.P1ID, match.P2ID},
+			EndReason:   match.EndReason,
+			StartedAt:   match.StartedAt,
+			Events:      make([]dto.ReplayEvent, 0, len(events)),
+		}
+		if match.WinnerID.Valid {
+			resp.WinnerID = &match.WinnerID.Int64
+		}
+		for _, e := range events {
+			resp.Events = append(resp.Events, dto.ReplayEvent{
+				AtMS:   e.CreatedAt.Sub(match.StartedAt).Milliseconds(),
+				Seat:   e.Player,
+				Turn:   e.Turn,
+				Action: e.Action,
+			})
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="match-%d.json"`, match.ID))
+		return lib.WriteJSON(w, http.StatusOK, resp)
+	}
+}
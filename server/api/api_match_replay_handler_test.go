@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+)
+
+func getMatchReplay(t *testing.T, user db.User, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := newAuthedRequest(http.MethodGet, "/api/matches/"+id+"/replay", nil, user)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	MatchReplayHandler(testQueries).ServeHTTP(w, req)
+	return w
+}
+
+func TestMatchReplayHandler(t *testing.T) {
+	alice := createTestUser(t, "replayalice")
+	bob := createTestUser(t, "replaybob")
+	match := createTestMatch(t, alice, bob, bob, time.Now().UTC().Truncate(time.Second))
+
+	inputs := []struct {
+		after  time.Duration
+		player int64
+		turn   int64
+		action string
+	}{
+		{250 * time.Millisecond, 0, 1, "left"},
+		{time.Second, 0, 1, "hard_drop"},
+		{3 * time.Second, 1, 2, "rotate_cw"},
+	}
+	for i, in := range inputs {
+		if err := testQueries.CreateMatchEvent(context.Background(), db.CreateMatchEventParams{
+			RoomID:    match.RoomID,
+			Seq:       int64(i + 1),
+			Player:    in.player,
+			Turn:      in.turn,
+			Action:    in.action,
+			CreatedAt: match.StartedAt.Add(in.after),
+		}); err != nil {
+			t.Fatalf("CreateMatchEvent error: %v", err)
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		w := getMatchReplay(t, alice, fmt.Sprint(match.ID))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, fmt.Sprintf("match-%d.json", match.ID)) {
+			t.Errorf("expected attachment named after the match, got %q", got)
+		}
+		if !strings.Contains(w.Body.String(), `[250,0,1,"left"]`) {
+			t.Errorf("expected compact events, got %s", w.Body.String())
+		}
+
+		var resp dto.Replay
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Version != dto.ReplayVersion || resp.MatchID != match.ID || resp.Seed != strconv.FormatInt(match.Seed, 10) {
+			t.Errorf("unexpected header: %+v", resp)
+		}
+		if resp.Players != [2]int64{alice.ID, bob.ID} || resp.WinnerID == nil || *resp.WinnerID != bob.ID {
+			t.Errorf("unexpected players: %v, winner %v", resp.Players, resp.WinnerID)
+		}
+		if len(resp.Events) != len(inputs) {
+			t.Fatalf("expected %d events, got %d", len(inputs), len(resp.Events))
+		}
+		for i, in := range inputs {
+			want := dto.ReplayEvent{AtMS: in.after.Milliseconds(), Seat: in.player, Turn: in.turn, Action: in.action}
+			if resp.Events[i] != want {
+				t.Errorf("event %d: expected %+v, got %+v", i, want, resp.Events[i])
+			}
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if w := getMatchReplay(t, alice, "999999"); w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		if w := getMatchReplay(t, alice, "abc"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+	"github.com/sodefrin/PP/server/matchmaking"
+)
+
+// EnqueueHandler puts the user in the matchmaking queue at their current
+// rating.
+func EnqueueHandler(queue *matchmaking.Queue, queries *db.Queries) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		inRoom, err := isInActiveRoom(r, queries, user.ID)
+		if err != nil {
+			return err
+		}
+		if inRoom {
+			return lib.NewError(http.StatusConflict, lib.CodeAlreadyInRoom, "Already in a room")
+		}
+
+		entry, err := queue.Enqueue(user.ID, user.Rating)
+		if err == matchmaking.ErrAlreadyQueued {
+			return lib.NewError(http.StatusConflict, lib.CodeAlreadyQueued, "Already queued")
+		}
+		if err != nil {
+			return err
+		}
+
+		return lib.WriteJSON(w, http.StatusAccepted, dto.QueueEntry{
+			UserID:   entry.UserID,
+			Rating:   entry.Rating,
+			JoinedAt: entry.JoinedAt.UTC(),
+		})
+	}
+}
+
+// DequeueHandler takes the user out of the matchmaking queue.
+func DequeueHandler(queue *matchmaking.Queue) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		if !queue.Dequeue(user.ID) {
+			return lib.NotFound("Not queued")
+		}
+		return lib.NoContent(w)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/matchmaking"
+)
+
+func TestMatchmakingHandlers(t *testing.T) {
+	queue := matchmaking.NewQueue(matchmaking.DefaultWindow)
+	user := createTestUser(t, "queueuser")
+	host := createTestUser(t, "queuehost")
+	createTestRoom(t, host)
+
+	enqueue := func(t *testing.T, u db.User) *httptest.ResponseRecorder {
+		t.Helper()
+		req := newAuthedRequest(http.MethodPost, "/api/matchmaking/queue", nil, u)
+		w := httptest.NewRecorder()
+		EnqueueHandler(queue, testQueries).ServeHTTP(w, req)
+		return w
+	}
+	dequeue := func(t *testing.T) *httptest.ResponseRecorder {
+		t.Helper()
+		req := newAuthedRequest(http.MethodDelete, "/api/matchmaking/queue", nil, user)
+		w := httptest.NewRecorder()
+		DequeueHandler(queue).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Enqueue", func(t *testing.T) {
+		w := enqueue(t, user)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected status 202, got %d", w.Code)
+		}
+		var resp dto.QueueEntry
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.UserID != user.ID || resp.Rating != user.Rating {
+			t.Errorf("expected user %d at %d, got %d at %d", user.ID, user.Rating, resp.UserID, resp.Rating)
+		}
+		if !queue.Contains(user.ID) {
+			t.Error("expected user to be queued")
+		}
+	})
+
+	t.Run("AlreadyQueued", func(t *testing.T) {
+		if w := enqueue(t, user); w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+	})
+
+	t.Run("InRoom", func(t *testing.T) {
+		if w := enqueue(t, host); w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+		if queue.Contains(host.ID) {
+			t.Error("expected host not to be queued")
+		}
+	})
+
+	t.Run("Dequeue", func(t *testing.T) {
+		if w := dequeue(t); w.Code != http.StatusNoContent {
+			t.Errorf("expected status 204, got %d", w.Code)
+		}
+		if queue.Contains(user.ID) {
+			t.Error("expected user to have left the queue")
+		}
+	})
+
+	t.Run("NotQueued", func(t *testing.T) {
+		if w := dequeue(t); w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+}
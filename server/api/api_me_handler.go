@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/sodefrin/PP/server/api/dto"
@@ -10,11 +9,6 @@ import (
 
 func MeHandler() lib.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return nil
-		}
-
 		user, err := lib.GetUserContext(r.Context())
 		if err != nil {
 			return err
@@ -25,16 +19,6 @@ func MeHandler() lib.HandlerFunc {
 			Name: user.Name,
 		}
 
-		respJSON, err := json.Marshal(resp)
-		if err != nil {
-			return err
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write(respJSON); err != nil {
-			return err
-		}
-		return nil
+		return lib.WriteJSON(w, http.StatusOK, resp)
 	}
 }
@@ -23,9 +23,7 @@ func TestMeHandler(t *testing.T) {
 		req = req.WithContext(ctx)
 
 		w := httptest.NewRecorder()
-		if err := MeHandler()(w, req); err != nil {
-			t.Fatalf("MeHandler error: %v", err)
-		}
+		MeHandler().ServeHTTP(w, req)
 
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status 200, got %d", w.Code)
@@ -46,16 +44,4 @@ func TestMeHandler(t *testing.T) {
 			t.Errorf("expected Name %s, got %s", user.Name, resp.Name)
 		}
 	})
-
-	t.Run("MethodNotAllowed", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/api/me", nil)
-		w := httptest.NewRecorder()
-		if err := MeHandler()(w, req); err != nil {
-			t.Fatalf("MeHandler error: %v", err)
-		}
-
-		if w.Code != http.StatusMethodNotAllowed {
-			t.Errorf("expected status 405, got %d", w.Code)
-		}
-	})
 }
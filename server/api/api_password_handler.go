@@ -0,0 +1,75 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// ChangePasswordHandler sets a new password for the authenticated user
+// once they confirm the current one. Their other sessions are revoked;
+// the one making the request stays signed in.
+func ChangePasswordHandler(conn *sql.DB, queries *db.Queries, passwords lib.PasswordPolicy) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+		session, err := lib.GetSessionContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		var req dto.ChangePasswordRequest
+		if err := lib.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+		if !lib.CheckPassword(user, req.CurrentPassword) {
+			return lib.NewError(http.StatusForbidden, lib.CodeInvalidCredentials, "Current password is incorrect").
+				WithField("current_password", "incorrect")
+		}
+		if err := passwords.Check("new_password", req.NewPassword); err != nil {
+			return err
+		}
+
+		hash, err := lib.HashPassword(req.NewPassword)
+		if err != nil {
+			return err
+		}
+		if err := lib.SetPassword(r.Context(), conn, queries, user.ID, hash, session.ID); err != nil {
+			return err
+		}
+		return lib.NoContent(w)
+	}
+}
+
+// ResetPasswordHandler sets a new password with a one-time reset token
+// from the reset-password command. The user is signed out everywhere and
+// signs in again with the new password.
+func ResetPasswordHandler(conn *sql.DB, queries *db.Queries, passwords lib.PasswordPolicy) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req dto.ResetPasswordRequest
+		if err := lib.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+		if err := passwords.Check("password", req.Password); err != nil {
+			return err
+		}
+
+		hash, err := lib.HashPassword(req.Password)
+		if err != nil {
+			return err
+		}
+		if err := lib.ResetPassword(r.Context(), conn, queries, req.Token, hash); err != nil {
+			if errors.Is(err, lib.ErrInvalidResetToken) {
+				return lib.BadRequest("Reset token is invalid or expired").WithField("token", "invalid or expired")
+			}
+			return err
+		}
+		return lib.NoContent(w)
+	}
+}
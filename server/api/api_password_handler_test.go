@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// createTestUserWithPassword creates a user who can sign in with password.
+func createTestUserWithPassword(t *testing.T, name, password string) db.User {
+	t.Helper()
+	hash, err := lib.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword error: %v", err)
+	}
+	user, err := testQueries.CreateUser(context.Background(), db.CreateUserParams{Name: name, PasswordHash: hash})
+	if err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	return user
+}
+
+func TestChangePasswordHandler(t *testing.T) {
+	user := createTestUserWithPassword(t, "changepw", "old password")
+	current := createTestSession(t, "changepw-current", user)
+	createTestSession(t, "changepw-other", user)
+	policy := lib.PasswordPolicy{MinLength: 8, Breached: map[string]struct{}{"letmein123": {}}}
+
+	request := func(body string) *httptest.ResponseRecorder {
+		req := newAuthedRequest(http.MethodPost, "/api/me/password", strings.NewReader(body), user)
+		req = req.WithContext(lib.SetSessionContext(req.Context(), current))
+		w := httptest.NewRecorder()
+		ChangePasswordHandler(testDB, testQueries, policy).ServeHTTP(w, req)
+		return w
+	}
+
+	for name, tt := range map[string]struct {
+		body   string
+		status int
+		field  string
+	}{
+		"WrongCurrent": {`{"current_password":"guess","new_password":"new password"}`, http.StatusForbidden, "current_password"},
+		"TooShort":     {`{"current_password":"old password","new_password":"short"}`, http.StatusBadRequest, "new_password"},
+		"Breached":     {`{"current_password":"old password","new_password":"letmein123"}`, http.StatusBadRequest, "new_password"},
+		"Missing":      {`{"current_password":"old password"}`, http.StatusBadRequest, "new_password"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := request(tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if e := decodeError(t, w); len(e.Fields) != 1 || e.Fields[0].Field != tt.field {
+				t.Errorf("expected %s to be reported, got %+v", tt.field, e)
+			}
+		})
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		if w := request(`{"current_password":"old password","new_password":"new password"}`); w.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", w.Code)
+		}
+
+		updated, err := testQueries.GetUser(context.Background(), user.ID)
+		if err != nil {
+			t.Fatalf("GetUser error: %v", err)
+		}
+		if !lib.CheckPassword(updated, "new password") || lib.CheckPassword(updated, "old password") {
+			t.Error("expected only the new password to work")
+		}
+		if _, err := testQueries.GetSession(context.Background(), current.ID); err != nil {
+			t.Errorf("expected the current session to survive, got %v", err)
+		}
+		assertSessionDeleted(t, "changepw-other")
+	})
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	user := createTestUserWithPassword(t, "resetpw", "forgotten password")
+	createTestSession(t, "resetpw-session", user)
+	token, err := lib.CreatePasswordReset(context.Background(), testDB, testQueries, user.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("CreatePasswordReset error: %v", err)
+	}
+
+	request := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/password/reset", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ResetPasswordHandler(testDB, testQueries, lib.DefaultPasswordPolicy()).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("WeakPassword", func(t *testing.T) {
+		w := request(`{"token":"` + token + `","password":"short"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", w.Code)
+		}
+		if e := decodeError(t, w); len(e.Fields) != 1 || e.Fields[0].Field != "password" {
+			t.Errorf("expected password to be reported, got %+v", e)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		if w := request(`{"token":"` + token + `","password":"remembered password"}`); w.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", w.Code)
+		}
+		updated, err := testQueries.GetUser(context.Background(), user.ID)
+		if err != nil {
+			t.Fatalf("GetUser error: %v", err)
+		}
+		if !lib.CheckPassword(updated, "remembered password") {
+			t.Error("expected the new password to work")
+		}
+		assertSessionDeleted(t, "resetpw-session")
+	})
+
+	t.Run("TokenUsed", func(t *testing.T) {
+		w := request(`{"token":"` + token + `","password":"another password"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", w.Code)
+		}
+		if e := decodeError(t, w); len(e.Fields) != 1 || e.Fields[0].Field != "token" {
+			t.Errorf("expected token to be reported, got %+v", e)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+func JoinRoomHandler(queries *db.Queries) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		roomID, ok := roomIDFromPath(r)
+		if !ok {
+			return lib.BadRequest("Invalid room id")
+		}
+
+		room, err := queries.GetRoom(r.Context(), roomID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return lib.NotFound("Room not found")
+			}
+			return err
+		}
+
+		if isRoomMember(room, user.ID) {
+			return lib.NewError(http.StatusConflict, lib.CodeAlreadyInRoom, "Already a member of this room")
+		}
+		if err := lib.ValidateRoomTransition(room.Status, lib.RoomStatusPlaying); err != nil {
+			return lib.NewError(http.StatusConflict, lib.CodeRoomNotOpen, "Room is not open")
+		}
+
+		inRoom, err := isInActiveRoom(r, queries, user.ID)
+		if err != nil {
+			return err
+		}
+		if inRoom {
+			return lib.NewError(http.StatusConflict, lib.CodeAlreadyInRoom, "Already in a room")
+		}
+
+		// JoinRoom only matches a waiting room, so a concurrent join loses here.
+		room, err = queries.JoinRoom(r.Context(), db.JoinRoomParams{
+			P2ID: sql.NullInt64{Int64: user.ID, Valid: true},
+			ID:   roomID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return lib.NewError(http.StatusConflict, lib.CodeRoomNotOpen, "Room is not open")
+			}
+			return err
+		}
+
+		return lib.WriteJSON(w, http.StatusOK, toRoomDTO(room))
+	}
+}
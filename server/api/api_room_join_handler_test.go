@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+func joinTestRoom(t *testing.T, user db.User, roomID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := newAuthedRequest(http.MethodPost, "/api/rooms/"+roomID+"/join", nil, user)
+	req.SetPathValue("id", roomID)
+	w := httptest.NewRecorder()
+	JoinRoomHandler(testQueries).ServeHTTP(w, req)
+	return w
+}
+
+func TestJoinRoomHandler(t *testing.T) {
+	host := createTestUser(t, "joinhost")
+	guest := createTestUser(t, "joinguest")
+	late := createTestUser(t, "joinlate")
+	room := createTestRoom(t, host)
+	roomID := fmt.Sprint(room.ID)
+
+	t.Run("OwnRoom", func(t *testing.T) {
+		if w := joinTestRoom(t, host, roomID); w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		w := joinTestRoom(t, guest, roomID)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		var resp dto.Room
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Status != lib.RoomStatusPlaying {
+			t.Errorf("expected status playing, got %s", resp.Status)
+		}
+		if resp.P2ID == nil || *resp.P2ID != guest.ID {
+			t.Errorf("expected p2 %d, got %v", guest.ID, resp.P2ID)
+		}
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		if w := joinTestRoom(t, late, roomID); w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if w := joinTestRoom(t, late, "999999"); w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		if w := joinTestRoom(t, late, "abc"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+}
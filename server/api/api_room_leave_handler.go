@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// RoomForfeiter ends the match a player walks out of.
+type RoomForfeiter interface {
+	Forfeit(ctx context.Context, room db.Room, userID int64) error
+}
+
+func LeaveRoomHandler(queries *db.Queries, rooms RoomForfeiter) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		roomID, ok := roomIDFromPath(r)
+		if !ok {
+			return lib.BadRequest("Invalid room id")
+		}
+
+		room, err := queries.GetRoom(r.Context(), roomID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return lib.NotFound("Room not found")
+			}
+			return err
+		}
+
+		if !isRoomMember(room, user.ID) {
+			return lib.NewError(http.StatusForbidden, lib.CodeForbidden, "Not a member of this room")
+		}
+		if err := lib.ValidateRoomTransition(room.Status, lib.RoomStatusFinished); err != nil {
+			return lib.NewError(http.StatusConflict, lib.CodeRoomNotOpen, "Room already finished")
+		}
+
+		// Leaving mid-game forfeits the match, which records it and
+		// finishes the room. Leaving must work even if that fails, so the
+		// room is finished here in any case.
+		if err := rooms.Forfeit(r.Context(), room, user.ID); err != nil {
+			slog.ErrorContext(r.Context(), "Forfeit error", "room_id", roomID, "error", err)
+		}
+		room, err = queries.GetRoom(r.Context(), roomID)
+		if err != nil {
+			return err
+		}
+		if room.Status != lib.RoomStatusFinished {
+			room, err = queries.UpdateRoomStatus(r.Context(), db.UpdateRoomStatusParams{
+				Status: lib.RoomStatusFinished,
+				ID:     roomID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return lib.WriteJSON(w, http.StatusOK, toRoomDTO(room))
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+	"github.com/sodefrin/PP/server/ws"
+)
+
+func leaveTestRoom(t *testing.T, user db.User, roomID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := newAuthedRequest(http.MethodPost, "/api/rooms/"+roomID+"/leave", nil, user)
+	req.SetPathValue("id", roomID)
+	w := httptest.NewRecorder()
+	LeaveRoomHandler(testQueries, ws.NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), ws.Options{})).ServeHTTP(w, req)
+	return w
+}
+
+func TestLeaveRoomHandler(t *testing.T) {
+	host := createTestUser(t, "leavehost")
+	stranger := createTestUser(t, "leavestranger")
+	room := createTestRoom(t, host)
+	roomID := fmt.Sprint(room.ID)
+
+	t.Run("NotMember", func(t *testing.T) {
+		if w := leaveTestRoom(t, stranger, roomID); w.Code != http.StatusForbidden {
+			t.Errorf("expected status 403, got %d", w.Code)
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		w := leaveTestRoom(t, host, roomID)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		var resp dto.Room
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Status != lib.RoomStatusFinished {
+			t.Errorf("expected status finished, got %s", resp.Status)
+		}
+	})
+
+	t.Run("AlreadyFinished", func(t *testing.T) {
+		if w := leaveTestRoom(t, host, roomID); w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+	})
+
+	t.Run("CanCreateAgain", func(t *testing.T) {
+		createTestRoom(t, host)
+	})
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/bot"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// BotStarter seats a bot in a room it is a player of.
+type BotStarter interface {
+	StartBot(room db.Room, user db.User, level bot.Level) error
+}
+
+func CreateRoomHandler(conn *sql.DB, queries *db.Queries, bots BotStarter) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		// The body is optional; an empty one means the defaults.
+		var req dto.CreateRoomRequest
+		if err := lib.DecodeJSON(w, r, &req); err != nil {
+			return err
+		}
+		sharedQueue := req.SharedQueue == nil || *req.SharedQueue
+		level := bot.Normal
+		if req.Opponent == dto.OpponentBot && req.BotLevel != "" {
+			if level, err = bot.ParseLevel(req.BotLevel); err != nil {
+				return err
+			}
+		}
+
+		inRoom, err := isInActiveRoom(r, queries, user.ID)
+		if err != nil {
+			return err
+		}
+		if inRoom {
+			return lib.NewError(http.StatusConflict, lib.CodeAlreadyInRoom, "Already in a room")
+		}
+
+		params := db.CreateRoomParams{
+			P1ID:        sql.NullInt64{Int64: user.ID, Valid: true},
+			Status:      lib.RoomStatusWaiting,
+			Seed:        rand.Int64(),
+			SharedQueue: sharedQueue,
+		}
+		if req.Opponent != dto.OpponentBot {
+			room, err := queries.CreateRoom(r.Context(), params)
+			if err != nil {
+				return err
+			}
+			return lib.WriteJSON(w, http.StatusCreated, toRoomDTO(room))
+		}
+
+		params.BotLevel = level.String()
+		room, botUser, err := createBotRoom(r, conn, queries, params)
+		if err != nil {
+			return err
+		}
+		if err := bots.StartBot(room, botUser, level); err != nil {
+			// Without its bot the match could never start.
+			if _, ferr := queries.UpdateRoomStatus(r.Context(), db.UpdateRoomStatusParams{
+				Status: lib.RoomStatusFinished,
+				ID:     room.ID,
+			}); ferr != nil {
+				slog.ErrorContext(r.Context(), "Failed to finish room without bot", "room_id", room.ID, "error", ferr)
+			}
+			return err
+		}
+		return lib.WriteJSON(w, http.StatusCreated, toRoomDTO(room))
+	}
+}
+
+// createBotRoom creates a room with the bot user already seated as
+// player 2, so that the match starts as soon as player 1 connects.
+func createBotRoom(r *http.Request, conn *sql.DB, queries *db.Queries, params db.CreateRoomParams) (db.Room, db.User, error) {
+	tx, err := conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		return db.Room{}, db.User{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := queries.WithTx(tx)
+
+	botUser, err := lib.BotUser(r.Context(), qtx)
+	if err != nil {
+		return db.Room{}, db.User{}, err
+	}
+	room, err := qtx.CreateRoom(r.Context(), params)
+	if err != nil {
+		return db.Room{}, db.User{}, err
+	}
+	room, err = qtx.JoinRoom(r.Context(), db.JoinRoomParams{
+		P2ID: sql.NullInt64{Int64: botUser.ID, Valid: true},
+		ID:   room.ID,
+	})
+	if err != nil {
+		return db.Room{}, db.User{}, err
+	}
+	return room, botUser, tx.Commit()
+}
+
+// SpectatorCounter reports how many spectators are watching a room.
+type SpectatorCounter interface {
+	Spectators(roomID int64) int
+}
+
+func ListRoomsHandler(queries *db.Queries, spectators SpectatorCounter) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var rooms []db.Room
+		var err error
+		switch status := r.URL.Query().Get("status"); status {
+		case "":
+			rooms, err = queries.ListRooms(r.Context())
+		case lib.RoomStatusWaiting, lib.RoomStatusPlaying, lib.RoomStatusFinished:
+			rooms, err = queries.ListRoomsByStatus(r.Context(), status)
+		default:
+			return lib.BadRequest("Invalid status")
+		}
+		if err != nil {
+			return err
+		}
+
+		resp := dto.RoomList{
+			Rooms: make([]dto.Room, 0, len(rooms)),
+		}
+		for _, room := range rooms {
+			item := toRoomDTO(room)
+			item.Spectators = spectators.Spectators(room.ID)
+			resp.Rooms = append(resp.Rooms, item)
+		}
+
+		return lib.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+func isInActiveRoom(r *http.Request, queries *db.Queries, userID int64) (bool, error) {
+	id := sql.NullInt64{Int64: userID, Valid: true}
+	_, err := queries.GetActiveRoomByUser(r.Context(), db.GetActiveRoomByUserParams{
+		P1ID: id,
+		P2ID: id,
+	})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func roomIDFromPath(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+func isRoomMember(room db.Room, userID int64) bool {
+	return (room.P1ID.Valid && room.P1ID.Int64 == userID) ||
+		(room.P2ID.Valid && room.P2ID.Int64 == userID)
+}
+
+func toRoomDTO(room db.Room) dto.Room {
+	resp := dto.Room{
+		ID:          room.ID,
+		Status:      room.Status,
+		SharedQueue: room.SharedQueue,
+	}
+	if room.P1ID.Valid {
+		resp.P1ID = &room.P1ID.Int64
+	}
+	if room.P2ID.Valid {
+		resp.P2ID = &room.P2ID.Int64
+	}
+	return resp
+}
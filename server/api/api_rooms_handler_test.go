@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/bot"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+func createTestRoom(t *testing.T, user db.User) dto.Room {
+	t.Helper()
+	req := newAuthedRequest(http.MethodPost, "/api/rooms", nil, user)
+	w := httptest.NewRecorder()
+	CreateRoomHandler(testDB, testQueries, nil).ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+
+	var room dto.Room
+	if err := json.NewDecoder(w.Body).Decode(&room); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return room
+}
+
+type botStart struct {
+	room  db.Room
+	user  db.User
+	level bot.Level
+}
+
+type fakeBots struct {
+	started []botStart
+	err     error
+}
+
+func (f *fakeBots) StartBot(room db.Room, user db.User, level bot.Level) error {
+	f.started = append(f.started, botStart{room, user, level})
+	return f.err
+}
+
+func TestCreateRoomHandler(t *testing.T) {
+	user := createTestUser(t, "roomcreator")
+
+	room := createTestRoom(t, user)
+	if room.Status != lib.RoomStatusWaiting {
+		t.Errorf("expected status waiting, got %s", room.Status)
+	}
+	if room.P1ID == nil || *room.P1ID != user.ID {
+		t.Errorf("expected p1 %d, got %v", user.ID, room.P1ID)
+	}
+	if room.P2ID != nil {
+		t.Errorf("expected no p2, got %d", *room.P2ID)
+	}
+	if !room.SharedQueue {
+		t.Error("expected a shared queue by default")
+	}
+
+	t.Run("IndependentQueues", func(t *testing.T) {
+		other := createTestUser(t, "roomcreatorsolo")
+		req := newAuthedRequest(http.MethodPost, "/api/rooms", strings.NewReader(`{"shared_queue":false}`), other)
+		w := httptest.NewRecorder()
+		CreateRoomHandler(testDB, testQueries, nil).ServeHTTP(w, req)
+		var room dto.Room
+		if err := json.NewDecoder(w.Body).Decode(&room); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if room.SharedQueue {
+			t.Error("expected independent queues")
+		}
+	})
+
+	t.Run("Bot", func(t *testing.T) {
+		other := createTestUser(t, "roomcreatorbot")
+		bots := &fakeBots{}
+		req := newAuthedRequest(http.MethodPost, "/api/rooms", strings.NewReader(`{"opponent":"bot","bot_level":"hard"}`), other)
+		w := httptest.NewRecorder()
+		CreateRoomHandler(testDB, testQueries, bots).ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d", w.Code)
+		}
+		var room dto.Room
+		if err := json.NewDecoder(w.Body).Decode(&room); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if room.Status != lib.RoomStatusPlaying {
+			t.Errorf("expected status playing, got %s", room.Status)
+		}
+		if len(bots.started) != 1 {
+			t.Fatalf("expected one bot to be started, got %d", len(bots.started))
+		}
+		started := bots.started[0]
+		if started.room.ID != room.ID || started.level != bot.Hard || !started.user.Bot {
+			t.Errorf("unexpected bot start %+v", started)
+		}
+		if room.P2ID == nil || *room.P2ID != started.user.ID {
+			t.Errorf("expected p2 %d, got %v", started.user.ID, room.P2ID)
+		}
+		if started.room.BotLevel != bot.Hard.String() {
+			t.Errorf("expected the bot level to be stored, got %q", started.room.BotLevel)
+		}
+	})
+
+	t.Run("BotFailed", func(t *testing.T) {
+		other := createTestUser(t, "roomcreatorbotfailed")
+		bots := &fakeBots{err: errors.New("no bot")}
+		req := newAuthedRequest(http.MethodPost, "/api/rooms", strings.NewReader(`{"opponent":"bot"}`), other)
+		w := httptest.NewRecorder()
+		CreateRoomHandler(testDB, testQueries, bots).ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", w.Code)
+		}
+		if len(bots.started) != 1 {
+			t.Fatalf("expected one bot start, got %d", len(bots.started))
+		}
+		room, err := testQueries.GetRoom(context.Background(), bots.started[0].room.ID)
+		if err != nil || room.Status != lib.RoomStatusFinished {
+			t.Errorf("expected the room to be finished, got %q (%v)", room.Status, err)
+		}
+	})
+
+	for name, body := range map[string]string{
+		"InvalidOpponent": `{"opponent":"robot"}`,
+		"InvalidBotLevel": `{"opponent":"bot","bot_level":"impossible"}`,
+		"UnknownField":    `{"shared":false}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := newAuthedRequest(http.MethodPost, "/api/rooms", strings.NewReader(body), createTestUser(t, "roomcreator"+name))
+			w := httptest.NewRecorder()
+			CreateRoomHandler(testDB, testQueries, &fakeBots{}).ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+
+	t.Run("InvalidBody", func(t *testing.T) {
+		other := createTestUser(t, "roomcreatorbad")
+		req := newAuthedRequest(http.MethodPost, "/api/rooms", strings.NewReader(`{`), other)
+		w := httptest.NewRecorder()
+		CreateRoomHandler(testDB, testQueries, nil).ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("AlreadyInRoom", func(t *testing.T) {
+		req := newAuthedRequest(http.MethodPost, "/api/rooms", nil, user)
+		w := httptest.NewRecorder()
+		CreateRoomHandler(testDB, testQueries, nil).ServeHTTP(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+	})
+}
+
+type spectatorCounts map[int64]int
+
+func (s spectatorCounts) Spectators(roomID int64) int {
+	return s[roomID]
+}
+
+func TestListRoomsHandler(t *testing.T) {
+	user := createTestUser(t, "roomlister")
+	created := createTestRoom(t, user)
+	spectators := spectatorCounts{created.ID: 3}
+
+	list := func(t *testing.T, target string) (int, dto.RoomList) {
+		t.Helper()
+		req := newAuthedRequest(http.MethodGet, target, nil, user)
+		w := httptest.NewRecorder()
+		ListRoomsHandler(testQueries, spectators).ServeHTTP(w, req)
+		var resp dto.RoomList
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	find := func(rooms []dto.Room, id int64) (dto.Room, bool) {
+		for _, r := range rooms {
+			if r.ID == id {
+				return r, true
+			}
+		}
+		return dto.Room{}, false
+	}
+	contains := func(rooms []dto.Room, id int64) bool {
+		_, ok := find(rooms, id)
+		return ok
+	}
+
+	t.Run("Open", func(t *testing.T) {
+		code, resp := list(t, "/api/rooms")
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", code)
+		}
+		room, ok := find(resp.Rooms, created.ID)
+		if !ok {
+			t.Fatalf("expected room %d in %v", created.ID, resp.Rooms)
+		}
+		if room.Spectators != 3 {
+			t.Errorf("expected 3 spectators, got %d", room.Spectators)
+		}
+	})
+
+	t.Run("ByStatus", func(t *testing.T) {
+		code, resp := list(t, "/api/rooms?status=playing")
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", code)
+		}
+		if contains(resp.Rooms, created.ID) {
+			t.Errorf("did not expect waiting room %d in playing list", created.ID)
+		}
+	})
+
+	t.Run("InvalidStatus", func(t *testing.T) {
+		if code, _ := list(t, "/api/rooms?status=bogus"); code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", code)
+		}
+	})
+}
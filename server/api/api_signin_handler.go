@@ -3,84 +3,41 @@ package api
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"net/http"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/sodefrin/PP/server/api/dto"
 	"github.com/sodefrin/PP/server/db"
 	"github.com/sodefrin/PP/server/lib"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func SigninHandler(queries *db.Queries) lib.HandlerFunc {
+func SigninHandler(queries *db.Queries, policy lib.SessionPolicy) lib.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return nil
-		}
-
 		var req dto.AuthRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return nil
+		if err := lib.DecodeJSON(w, r, &req); err != nil {
+			return err
 		}
 
 		user, err := queries.GetUserByName(context.Background(), req.Name)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "Invalid credentials", http.StatusUnauthorized)
-				return nil
+				return lib.NewError(http.StatusUnauthorized, lib.CodeInvalidCredentials, "Invalid credentials")
 			}
 			return err
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
-			return nil
+		if !lib.CheckPassword(user, req.Password) {
+			return lib.NewError(http.StatusUnauthorized, lib.CodeInvalidCredentials, "Invalid credentials")
 		}
 
-		// Create session
-		sessionID := uuid.New().String()
-		expiresAt := time.Now().Add(24 * time.Hour)
-
-		sessionParams := db.CreateSessionParams{
-			ID:        sessionID,
-			UserID:    user.ID,
-			ExpiresAt: expiresAt,
-		}
-
-		_, err = queries.CreateSession(context.Background(), sessionParams)
-		if err != nil {
+		if _, err := lib.CreateSession(r.Context(), w, queries, policy, user.ID); err != nil {
 			return err
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_id",
-			Value:    sessionID,
-			Expires:  expiresAt,
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteStrictMode,
-			Path:     "/",
-		})
-
 		resp := dto.User{
 			ID:   user.ID,
 			Name: user.Name,
 		}
 
-		userJSON, err := json.Marshal(resp)
-		if err != nil {
-			return err
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write(userJSON); err != nil {
-			return err
-		}
-		return nil
+		return lib.WriteJSON(w, http.StatusOK, resp)
 	}
 }
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/sodefrin/PP/server/lib"
 )
 
 func TestSignin(t *testing.T) {
@@ -17,7 +19,7 @@ func TestSignin(t *testing.T) {
 	sBody, _ := json.Marshal(signupBody)
 	sReq := httptest.NewRequest(http.MethodPost, "/api/signup", bytes.NewBuffer(sBody))
 	sW := httptest.NewRecorder()
-	if err := SignupHandler(testQueries)(sW, sReq); err != nil {
+	if err := SignupHandler(testQueries, lib.DefaultSessionPolicy(), lib.DefaultPasswordPolicy())(sW, sReq); err != nil {
 		t.Fatalf("SignupHandler error: %v", err)
 	}
 
@@ -30,9 +32,7 @@ func TestSignin(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/api/signin", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
 
-	if err := SigninHandler(testQueries)(w, req); err != nil {
-		t.Fatalf("SigninHandler error: %v", err)
-	}
+	SigninHandler(testQueries, lib.DefaultSessionPolicy()).ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", w.Code)
@@ -64,11 +64,12 @@ func TestSigninInvalid(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/api/signin", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
 
-	if err := SigninHandler(testQueries)(w, req); err != nil {
-		t.Fatalf("SigninHandler error: %v", err)
-	}
+	SigninHandler(testQueries, lib.DefaultSessionPolicy()).ServeHTTP(w, req)
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected status 401, got %d", w.Code)
 	}
+	if e := decodeError(t, w); e.Code != lib.CodeInvalidCredentials {
+		t.Errorf("expected invalid_credentials, got %+v", e)
+	}
 }
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// SignoutHandler deletes the session named by the session_id cookie and
+// clears the cookie. It does not require a valid session, so a client with
+// an expired cookie can still sign out cleanly.
+func SignoutHandler(queries *db.Queries) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if cookie, err := r.Cookie("session_id"); err == nil && cookie.Value != "" {
+			if err := queries.DeleteSession(r.Context(), cookie.Value); err != nil {
+				return err
+			}
+		}
+
+		lib.ClearSessionCookie(w)
+		return lib.NoContent(w)
+	}
+}
+
+// SignoutAllHandler revokes every session belonging to the authenticated
+// user, including the current one.
+func SignoutAllHandler(queries *db.Queries) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+
+		if err := queries.DeleteSessionsByUserID(r.Context(), user.ID); err != nil {
+			return err
+		}
+
+		lib.ClearSessionCookie(w)
+		return lib.NoContent(w)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/db"
+)
+
+func createTestSession(t *testing.T, id string, user db.User) db.Session {
+	t.Helper()
+	session, err := testQueries.CreateSession(context.Background(), db.CreateSessionParams{
+		ID:         id,
+		UserID:     user.ID,
+		ExpiresAt:  time.Now().Add(time.Hour),
+		CreatedAt:  time.Now(),
+		LastSeenAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateSession error: %v", err)
+	}
+	return session
+}
+
+func assertSessionDeleted(t *testing.T, id string) {
+	t.Helper()
+	if _, err := testQueries.GetSession(context.Background(), id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected session %s to be deleted, got %v", id, err)
+	}
+}
+
+func assertCookieCleared(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session_id" {
+			if c.Value != "" || c.MaxAge >= 0 {
+				t.Errorf("expected session cookie to be cleared, got %+v", c)
+			}
+			return
+		}
+	}
+	t.Error("session cookie not cleared")
+}
+
+func TestSignout(t *testing.T) {
+	user := createTestUser(t, "signoutuser")
+	session := createTestSession(t, "signout-session", user)
+	other := createTestSession(t, "signout-other", user)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/signout", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: session.ID})
+	w := httptest.NewRecorder()
+	SignoutHandler(testQueries).ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+	assertCookieCleared(t, w)
+	assertSessionDeleted(t, session.ID)
+	if _, err := testQueries.GetSession(context.Background(), other.ID); err != nil {
+		t.Errorf("expected other session to survive, got %v", err)
+	}
+}
+
+func TestSignoutWithoutSession(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/signout", nil)
+	w := httptest.NewRecorder()
+	SignoutHandler(testQueries).ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+	assertCookieCleared(t, w)
+}
+
+func TestSignoutAll(t *testing.T) {
+	user := createTestUser(t, "signoutalluser")
+	bystander := createTestUser(t, "signoutallbystander")
+	first := createTestSession(t, "signout-all-1", user)
+	second := createTestSession(t, "signout-all-2", user)
+	kept := createTestSession(t, "signout-all-kept", bystander)
+
+	req := newAuthedRequest(http.MethodPost, "/api/signout/all", nil, user)
+	w := httptest.NewRecorder()
+	SignoutAllHandler(testQueries).ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+	assertCookieCleared(t, w)
+	assertSessionDeleted(t, first.ID)
+	assertSessionDeleted(t, second.ID)
+	if _, err := testQueries.GetSession(context.Background(), kept.ID); err != nil {
+		t.Errorf("expected other user's session to survive, got %v", err)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/sodefrin/PP/server/lib"
 )
 
 func TestSignup(t *testing.T) {
@@ -17,9 +20,7 @@ func TestSignup(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/api/signup", bytes.NewBuffer(body))
 	w := httptest.NewRecorder()
 
-	if err := SignupHandler(testQueries)(w, req); err != nil {
-		t.Fatalf("SignupHandler error: %v", err)
-	}
+	SignupHandler(testQueries, lib.DefaultSessionPolicy(), lib.DefaultPasswordPolicy()).ServeHTTP(w, req)
 
 	if w.Code != http.StatusCreated {
 		t.Errorf("Expected status 201, got %d", w.Code)
@@ -63,9 +64,7 @@ func TestSignupDuplicate(t *testing.T) {
 	// First creation
 	req1 := httptest.NewRequest(http.MethodPost, "/api/signup", bytes.NewBuffer(body))
 	w1 := httptest.NewRecorder()
-	if err := SignupHandler(testQueries)(w1, req1); err != nil {
-		t.Fatalf("SignupHandler error: %v", err)
-	}
+	SignupHandler(testQueries, lib.DefaultSessionPolicy(), lib.DefaultPasswordPolicy()).ServeHTTP(w1, req1)
 
 	if w1.Code != http.StatusCreated {
 		t.Fatalf("Failed to create initial user: %d", w1.Code)
@@ -74,11 +73,56 @@ func TestSignupDuplicate(t *testing.T) {
 	// Second creation (duplicate)
 	req2 := httptest.NewRequest(http.MethodPost, "/api/signup", bytes.NewBuffer(body))
 	w2 := httptest.NewRecorder()
-	if err := SignupHandler(testQueries)(w2, req2); err != nil {
-		t.Fatalf("SignupHandler error: %v", err)
-	}
+	SignupHandler(testQueries, lib.DefaultSessionPolicy(), lib.DefaultPasswordPolicy()).ServeHTTP(w2, req2)
 
 	if w2.Code != http.StatusConflict {
 		t.Errorf("Expected status 409 for duplicate user, got %d", w2.Code)
 	}
+	if e := decodeError(t, w2); e.Code != lib.CodeNameTaken || len(e.Fields) != 1 || e.Fields[0].Field != "name" {
+		t.Errorf("expected name_taken on name, got %+v", e)
+	}
+}
+
+func TestSignupMissingFields(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/signup", bytes.NewBufferString(`{"name":"nopassword"}`))
+	w := httptest.NewRecorder()
+	SignupHandler(testQueries, lib.DefaultSessionPolicy(), lib.DefaultPasswordPolicy()).ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	if e := decodeError(t, w); e.Code != lib.CodeInvalidRequest || len(e.Fields) != 1 || e.Fields[0].Field != "password" {
+		t.Errorf("expected password to be reported, got %+v", e)
+	}
+}
+
+func TestSignupValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		field  string
+	}{
+		{"NameTooShort", `{"name":"ab","password":"password123"}`, http.StatusBadRequest, "name"},
+		{"NameCharset", `{"name":"a b<c>","password":"password123"}`, http.StatusBadRequest, "name"},
+		{"WeakPassword", `{"name":"weakpass","password":"short"}`, http.StatusBadRequest, "password"},
+		{"PasswordTooLong", `{"name":"longpass","password":"` + strings.Repeat("x", 73) + `"}`, http.StatusBadRequest, "password"},
+		{"UnknownField", `{"name":"admin1","password":"password123","admin":true}`, http.StatusBadRequest, "admin"},
+		{"BodyTooLarge", `{"name":"` + strings.Repeat("a", lib.MaxBodyBytes) + `","password":"x"}`, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/signup", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			SignupHandler(testQueries, lib.DefaultSessionPolicy(), lib.DefaultPasswordPolicy()).ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			e := decodeError(t, w)
+			if tt.field != "" && (len(e.Fields) != 1 || e.Fields[0].Field != tt.field) {
+				t.Errorf("expected %s to be reported, got %+v", tt.field, e)
+			}
+		})
+	}
 }
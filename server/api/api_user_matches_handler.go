@@ -0,0 +1,109 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// UserMatchesHandler lists the finished matches of a user, newest first.
+func UserMatchesHandler(queries *db.Queries) lib.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil || userID <= 0 {
+			return lib.BadRequest("Invalid user id")
+		}
+		limit, offset, ok := pageFromQuery(r)
+		if !ok {
+			return lib.BadRequest("Invalid limit or offset")
+		}
+
+		if _, err := queries.GetUser(r.Context(), userID); err != nil {
+			if err == sql.ErrNoRows {
+				return lib.NotFound("User not found")
+			}
+			return err
+		}
+
+		matches, err := queries.ListMatchesByUser(r.Context(), db.ListMatchesByUserParams{
+			P1ID:   userID,
+			P2ID:   userID,
+			Limit:  limit,
+			Offset: offset,
+		})
+		if err != nil {
+			return err
+		}
+		total, err := queries.CountMatchesByUser(r.Context(), db.CountMatchesByUserParams{
+			P1ID: userID,
+			P2ID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		resp := dto.MatchList{
+			Matches: make([]dto.Match, 0, len(matches)),
+			Total:   total,
+			Limit:   limit,
+			Offset:  offset,
+		}
+		for _, m := range matches {
+			resp.Matches = append(resp.Matches, toMatchDTO(m))
+		}
+
+		return lib.WriteJSON(w, http.StatusOK, resp)
+	}
+}
+
+// pageFromQuery reads the limit and offset query parameters, applying the
+// default limit and capping it at maxPageLimit.
+func pageFromQuery(r *http.Request) (limit, offset int64, ok bool) {
+	limit, offset = defaultPageLimit, 0
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		limit = min(n, maxPageLimit)
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+func toMatchDTO(m db.Match) dto.Match {
+	resp := dto.Match{
+		ID:              m.ID,
+		RoomID:          m.RoomID,
+		P1ID:            m.P1ID,
+		P2ID:            m.P2ID,
+		P1Score:         m.P1Score,
+		P2Score:         m.P2Score,
+		P1MaxChain:      m.P1MaxChain,
+		P2MaxChain:      m.P2MaxChain,
+		EndReason:       m.EndReason,
+		StartedAt:       m.StartedAt,
+		EndedAt:         m.EndedAt,
+		DurationSeconds: int64(m.EndedAt.Sub(m.StartedAt).Seconds()),
+	}
+	if m.WinnerID.Valid {
+		resp.WinnerID = &m.WinnerID.Int64
+	}
+	return resp
+}
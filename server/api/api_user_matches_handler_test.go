@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/api/dto"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+func createTestMatch(t *testing.T, p1, p2, winner db.User, endedAt time.Time) db.Match {
+	t.Helper()
+	ctx := context.Background()
+	room, err := testQueries.CreateRoom(ctx, db.CreateRoomParams{
+		P1ID:   sql.NullInt64{Int64: p1.ID, Valid: true},
+		Status: lib.RoomStatusFinished,
+	})
+	if err != nil {
+		t.Fatalf("CreateRoom error: %v", err)
+	}
+	match, err := testQueries.CreateMatch(ctx, db.CreateMatchParams{
+		RoomID:    room.ID,
+		P1ID:      p1.ID,
+		P2ID:      p2.ID,
+		WinnerID:  sql.NullInt64{Int64: winner.ID, Valid: true},
+		P1Score:   1200,
+		P2Score:   300,
+		EndReason: lib.MatchEndTopOut,
+		StartedAt: endedAt.Add(-90 * time.Second),
+		EndedAt:   endedAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateMatch error: %v", err)
+	}
+	return match
+}
+
+func getUserMatches(t *testing.T, user db.User, id, query string) (int, dto.MatchList) {
+	t.Helper()
+	req := newAuthedRequest(http.MethodGet, "/api/users/"+id+"/matches"+query, nil, user)
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	UserMatchesHandler(testQueries).ServeHTTP(w, req)
+	var resp dto.MatchList
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestUserMatchesHandler(t *testing.T) {
+	alice := createTestUser(t, "historyalice")
+	bob := createTestUser(t, "historybob")
+	carol := createTestUser(t, "historycarol")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	oldest := createTestMatch(t, alice, bob, alice, now.Add(-2*time.Hour))
+	middle := createTestMatch(t, bob, alice, bob, now.Add(-time.Hour))
+	newest := createTestMatch(t, alice, carol, carol, now)
+	createTestMatch(t, bob, carol, bob, now)
+	aliceID := fmt.Sprint(alice.ID)
+
+	t.Run("NewestFirst", func(t *testing.T) {
+		code, resp := getUserMatches(t, bob, aliceID, "")
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", code)
+		}
+		if resp.Total != 3 || len(resp.Matches) != 3 {
+			t.Fatalf("expected 3 matches, got total %d and %d", resp.Total, len(resp.Matches))
+		}
+		for i, want := range []int64{newest.ID, middle.ID, oldest.ID} {
+			if resp.Matches[i].ID != want {
+				t.Errorf("match %d: expected id %d, got %d", i, want, resp.Matches[i].ID)
+			}
+		}
+		first := resp.Matches[0]
+		if first.WinnerID == nil || *first.WinnerID != carol.ID {
+			t.Errorf("expected winner %d, got %v", carol.ID, first.WinnerID)
+		}
+		if first.DurationSeconds != 90 || first.EndReason != lib.MatchEndTopOut {
+			t.Errorf("unexpected match: %+v", first)
+		}
+	})
+
+	t.Run("Paginated", func(t *testing.T) {
+		code, resp := getUserMatches(t, alice, aliceID, "?limit=1&offset=1")
+		if code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", code)
+		}
+		if resp.Total != 3 || resp.Limit != 1 || resp.Offset != 1 {
+			t.Errorf("unexpected page: %+v", resp)
+		}
+		if len(resp.Matches) != 1 || resp.Matches[0].ID != middle.ID {
+			t.Errorf("expected only match %d, got %+v", middle.ID, resp.Matches)
+		}
+	})
+
+	t.Run("InvalidPage", func(t *testing.T) {
+		for _, query := range []string{"?limit=0", "?limit=x", "?offset=-1"} {
+			if code, _ := getUserMatches(t, alice, aliceID, query); code != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d", query, code)
+			}
+		}
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		if code, _ := getUserMatches(t, alice, "999999", ""); code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", code)
+		}
+	})
+}
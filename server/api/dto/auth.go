@@ -1,6 +1,29 @@
 package dto
 
+// SignupRequest is the body of POST /api/signup. Names are limited to
+// ASCII letters, digits, _, - and . so that they display the same
+// everywhere. The password is checked against the password policy.
+type SignupRequest struct {
+	Name     string `json:"name" validate:"required,min=3,max=32,charset=name"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
+}
+
+// AuthRequest is the body of POST /api/signin. Only sizes are checked, so
+// that users who signed up before the name rules existed can still sign in.
 type AuthRequest struct {
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
+}
+
+// ChangePasswordRequest is the body of POST /api/me/password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required,maxbytes=72"`
+	NewPassword     string `json:"new_password" validate:"required,maxbytes=72"`
+}
+
+// ResetPasswordRequest is the body of POST /api/password/reset. Token is
+// a one-time reset token generated by an admin.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
 }
@@ -0,0 +1,17 @@
+package dto
+
+type LeaderboardEntry struct {
+	Rank   int64  `json:"rank"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	Rating int64  `json:"rating"`
+}
+
+type Leaderboard struct {
+	Entries []LeaderboardEntry `json:"entries"`
+	Total   int64              `json:"total"`
+	Limit   int64              `json:"limit"`
+	Offset  int64              `json:"offset"`
+	// Me is the caller's own entry, wherever it falls.
+	Me LeaderboardEntry `json:"me"`
+}
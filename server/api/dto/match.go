@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+type Match struct {
+	ID              int64     `json:"id"`
+	RoomID          int64     `json:"room_id"`
+	P1ID            int64     `json:"p1_id"`
+	P2ID            int64     `json:"p2_id"`
+	WinnerID        *int64    `json:"winner_id"`
+	P1Score         int64     `json:"p1_score"`
+	P2Score         int64     `json:"p2_score"`
+	P1MaxChain      int64     `json:"p1_max_chain"`
+	P2MaxChain      int64     `json:"p2_max_chain"`
+	EndReason       string    `json:"end_reason"`
+	StartedAt       time.Time `json:"started_at"`
+	EndedAt         time.Time `json:"ended_at"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+type MatchList struct {
+	Matches []Match `json:"matches"`
+	Total   int64   `json:"total"`
+	Limit   int64   `json:"limit"`
+	Offset  int64   `json:"offset"`
+}
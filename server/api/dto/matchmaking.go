@@ -0,0 +1,11 @@
+package dto
+
+import "time"
+
+// QueueEntry is the response to joining the matchmaking queue. The match
+// itself is announced over the websocket with match_found.
+type QueueEntry struct {
+	UserID   int64     `json:"user_id"`
+	Rating   int64     `json:"rating"`
+	JoinedAt time.Time `json:"joined_at"`
+}
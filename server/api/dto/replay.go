@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ReplayVersion is the version of the Replay format.
+const ReplayVersion = 1
+
+// Replay is the body of GET /api/matches/{id}/replay. It holds everything
+// needed to rebuild a match with game.Replay:
+//
+//	{
+//	  "version": 1,
+//	  "match_id": 7,
+//	  "seed": "8041542437862443016",
+//	  "shared_queue": true,
+//	  "players": [3, 5],
+//	  "winner_id": 5,
+//	  "end_reason": "topout",
+//	  "started_at": "2024-01-01T18:00:00Z",
+//	  "events": [[0, 0, 1, "left"], [412, 0, 1, "hard_drop"], ...]
+//	}
+//
+// seed is a decimal string because it does not fit in a JavaScript
+// number. players holds the user ids of p1 and p2. Each event is a
+// ReplayEvent, in the order the server applied them.
+type Replay struct {
+	Version     int           `json:"version"`
+	MatchID     int64         `json:"match_id"`
+	Seed        string        `json:"seed"`
+	SharedQueue bool          `json:"shared_queue"`
+	Players     [2]int64      `json:"players"`
+	WinnerID    *int64        `json:"winner_id"`
+	EndReason   string        `json:"end_reason"`
+	StartedAt   time.Time     `json:"started_at"`
+	Events      []ReplayEvent `json:"events"`
+}
+
+// ReplayEvent is one accepted input, encoded as the array
+// [ms since started_at, seat (0 for p1, 1 for p2), turn, action].
+type ReplayEvent struct {
+	AtMS   int64
+	Seat   int64
+	Turn   int64
+	Action string
+}
+
+func (e ReplayEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{e.AtMS, e.Seat, e.Turn, e.Action})
+}
+
+func (e *ReplayEvent) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 4 {
+		return fmt.Errorf("replay event: expected 4 fields, got %d", len(fields))
+	}
+	for i, dst := range []any{&e.AtMS, &e.Seat, &e.Turn, &e.Action} {
+		if err := json.Unmarshal(fields[i], dst); err != nil {
+			return fmt.Errorf("replay event field %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package dto
+
+// Opponents that can be asked for when creating a room.
+const (
+	OpponentHuman = ""
+	OpponentBot   = "bot"
+)
+
+// CreateRoomRequest is the optional body of POST /api/rooms. SharedQueue
+// defaults to true, giving both players the same pair sequence. With
+// Opponent set to "bot" the room starts at once against a bot of
+// BotLevel: easy, normal (the default) or hard.
+type CreateRoomRequest struct {
+	SharedQueue *bool  `json:"shared_queue"`
+	Opponent    string `json:"opponent" validate:"oneof=bot"`
+	BotLevel    string `json:"bot_level" validate:"oneof=easy normal hard"`
+}
+
+type Room struct {
+	ID          int64  `json:"id"`
+	P1ID        *int64 `json:"p1_id"`
+	P2ID        *int64 `json:"p2_id"`
+	Status      string `json:"status"`
+	SharedQueue bool   `json:"shared_queue"`
+	// Spectators is only filled in by the room listing.
+	Spectators int `json:"spectators"`
+}
+
+type RoomList struct {
+	Rooms []Room `json:"rooms"`
+}
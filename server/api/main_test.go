@@ -3,16 +3,24 @@ package api
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
 
 	_ "modernc.org/sqlite"
 )
 
-var testQueries *db.Queries
+var (
+	testDB      *sql.DB
+	testQueries *db.Queries
+)
 
 func TestMain(m *testing.M) {
 	// Setup DB
@@ -22,21 +30,15 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	// Read schema file
-	schemaBytes, err := os.ReadFile("../db/schema.sql")
-	if err != nil {
-		slog.ErrorContext(context.Background(), "Failed to read schema file", "error", err)
-		os.Exit(1)
-	}
-
-	// Execute schema
-	if _, err := dbConn.Exec(string(schemaBytes)); err != nil {
-		slog.ErrorContext(context.Background(), "Failed to execute schema", "error", err)
+	// Apply migrations
+	if err := db.Migrate(context.Background(), dbConn); err != nil {
+		slog.ErrorContext(context.Background(), "Failed to apply migrations", "error", err)
 		os.Exit(1)
 	}
 
 	// Initialize queries
 	// Initialize queries
+	testDB = dbConn
 	testQueries = db.New(dbConn)
 
 	code := m.Run()
@@ -46,3 +48,33 @@ func TestMain(m *testing.M) {
 	}
 	os.Exit(code)
 }
+
+func createTestUser(t *testing.T, name string) db.User {
+	t.Helper()
+	user, err := testQueries.CreateUser(context.Background(), db.CreateUserParams{
+		Name:         name,
+		PasswordHash: "x",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	return user
+}
+
+func newAuthedRequest(method, target string, body io.Reader, user db.User) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	return req.WithContext(lib.SetUserContext(req.Context(), user))
+}
+
+// decodeError decodes the JSON error body of a failed request.
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) lib.ErrorBody {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected a JSON error, got %q", ct)
+	}
+	var resp lib.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	return resp.Error
+}
@@ -6,42 +6,36 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/sodefrin/PP/server/lib"
+	"github.com/sodefrin/PP/server/ws"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	// Allow all origins for development
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
+func WsHandler(hub *ws.Hub, checkOrigin func(r *http.Request) bool) lib.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     checkOrigin,
+	}
 
-func WsHandler() lib.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		conn, err := upgrader.Upgrade(w, r, nil)
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			return err
+		}
+		session, err := lib.GetSessionContext(r.Context())
 		if err != nil {
 			return err
 		}
-		defer func() {
-			if err := conn.Close(); err != nil {
-				slog.ErrorContext(r.Context(), "Failed to close websocket connection", "error", err)
-			}
-		}()
 
-		slog.InfoContext(r.Context(), "Client connected")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied with an HTTP error.
+			slog.InfoContext(r.Context(), "Websocket upgrade rejected", "error", err, "origin", r.Header.Get("Origin"))
+			return nil
+		}
 
-		for {
-			messageType, p, err := conn.ReadMessage()
-			if err != nil {
-				return err
-			}
-			slog.InfoContext(r.Context(), "Received message", "payload", string(p))
+		slog.InfoContext(r.Context(), "Client connected")
 
-			// Echo message back
-			if err := conn.WriteMessage(messageType, p); err != nil {
-				return err
-			}
-		}
+		// The hub owns the connection from here on and closes it.
+		return hub.Serve(r.Context(), conn, user, session.ID)
 	}
 }
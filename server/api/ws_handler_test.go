@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+	"github.com/sodefrin/PP/server/ws"
+)
+
+func TestWsHandlerOrigin(t *testing.T) {
+	user := createTestUser(t, "wsorigin")
+	session := db.Session{ID: "wsorigin-session", UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	check := lib.NewOriginChecker([]string{"https://puyo.example.com"}, false)
+	handler := WsHandler(ws.NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), ws.Options{}), check)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := lib.SetUserContext(r.Context(), user)
+		ctx = lib.SetSessionContext(ctx, session)
+		if err := handler(w, r.WithContext(ctx)); err != nil {
+			t.Errorf("WsHandler error: %v", err)
+		}
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"Allowed", "https://puyo.example.com", http.StatusSwitchingProtocols},
+		{"SameOrigin", srv.URL, http.StatusSwitchingProtocols},
+		{"Rejected", "https://evil.example.com", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Origin", tt.origin)
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				_ = conn.Close()
+			}
+			if resp == nil {
+				t.Fatalf("expected a response, got error %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestWsHandlerRequiresAuth(t *testing.T) {
+	check := lib.NewOriginChecker(nil, true)
+	handler := lib.RequireAuthMiddleware(WsHandler(ws.NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), ws.Options{}), check))
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+}
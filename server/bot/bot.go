@@ -0,0 +1,155 @@
+// Package bot chooses placements for computer opponents by searching over
+// the server-side board engine. It knows nothing about rooms or the wire
+// protocol; ws seats a bot and feeds it the states a human would see.
+package bot
+
+import (
+	"errors"
+
+	"github.com/sodefrin/PP/server/game"
+)
+
+var ErrUnknownLevel = errors.New("unknown bot level")
+
+// Level is how far ahead a bot looks: the current pair only, or one or
+// both preview pairs as well.
+type Level int
+
+const (
+	Easy Level = iota + 1
+	Normal
+	Hard
+)
+
+var levelNames = [...]string{
+	Easy:   "easy",
+	Normal: "normal",
+	Hard:   "hard",
+}
+
+func (l Level) String() string {
+	if l >= Easy && l <= Hard {
+		return levelNames[l]
+	}
+	return "unknown"
+}
+
+func ParseLevel(s string) (Level, error) {
+	for l := Easy; l <= Hard; l++ {
+		if levelNames[l] == s {
+			return l, nil
+		}
+	}
+	return 0, ErrUnknownLevel
+}
+
+// Depth is the number of pairs searched, the current one included.
+func (l Level) Depth() int {
+	return int(l)
+}
+
+// Placement is where to hard drop a pair: Main in Col, Sub in the
+// direction of Rot.
+type Placement struct {
+	Col int
+	Rot game.Rotation
+}
+
+// Weights of the board evaluation. Chain points dominate; the rest breaks
+// ties towards boards that are low and keep colours together.
+const (
+	lost          = -1 << 30
+	connectWeight = 8
+	dangerHeight  = game.Rows - 3
+	dangerPenalty = 1000
+)
+
+// Plan returns the placement of pairs[0] that leads to the best board
+// after placing up to depth pairs in order. It maximises the chain points
+// scored along the way plus an evaluation of the final board; placements
+// that top out are only chosen when nothing else is left.
+func Plan(board *game.Board, pairs []game.Pair, depth int) Placement {
+	if len(pairs) == 0 {
+		return Placement{Col: game.SpawnCol, Rot: game.RotUp}
+	}
+	depth = max(1, min(depth, len(pairs)))
+	placement, _ := search(board, pairs[:depth])
+	return placement
+}
+
+func search(board *game.Board, pairs []game.Pair) (Placement, int) {
+	best := Placement{Col: game.SpawnCol, Rot: game.RotUp}
+	bestValue := lost - 1
+	for col := 0; col < game.Cols; col++ {
+		for rot := game.RotUp; rot <= game.RotLeft; rot++ {
+			next := board.Clone()
+			if err := next.Place(pairs[0], col, rot); err != nil {
+				continue
+			}
+			value := 0
+			for _, step := range next.Resolve() {
+				value += step.Score
+			}
+			switch {
+			case next.At(0, game.SpawnCol) != game.Empty:
+				value = lost
+			case len(pairs) > 1:
+				_, rest := search(next, pairs[1:])
+				value += rest
+			default:
+				value += evaluate(next)
+			}
+			if value > bestValue {
+				best, bestValue = Placement{Col: col, Rot: rot}, value
+			}
+		}
+	}
+	return best, bestValue
+}
+
+// evaluate scores a settled board: same-colour neighbours count for it,
+// tall columns against it, and a nearly full spawn column heavily so.
+func evaluate(board *game.Board) int {
+	value := 0
+	for c := 0; c < game.Cols; c++ {
+		h := board.Height(c)
+		value -= h * h
+		if c == game.SpawnCol && h >= dangerHeight {
+			value -= dangerPenalty
+		}
+	}
+	for r := 0; r < game.Rows; r++ {
+		for c := 0; c < game.Cols; c++ {
+			color := board.At(r, c)
+			if color == game.Empty || color == game.Garbage {
+				continue
+			}
+			if c+1 < game.Cols && board.At(r, c+1) == color {
+				value += connectWeight
+			}
+			if r+1 < game.Rows && board.At(r+1, c) == color {
+				value += connectWeight
+			}
+		}
+	}
+	return value
+}
+
+// Next returns the action that steers piece towards target: rotate
+// first, then shift, then hard drop.
+func Next(piece game.Piece, target Placement) game.Action {
+	switch turns := (target.Rot - piece.Rot + 4) % 4; turns {
+	case 0:
+	case 3:
+		return game.ActionRotateCCW
+	default:
+		return game.ActionRotateCW
+	}
+	switch {
+	case piece.Col < target.Col:
+		return game.ActionRight
+	case piece.Col > target.Col:
+		return game.ActionLeft
+	}
+	return game.ActionHardDrop
+}
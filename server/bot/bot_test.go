@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/sodefrin/PP/server/game"
+)
+
+func mustParse(t *testing.T, rows ...string) *game.Board {
+	t.Helper()
+	b, err := game.ParseBoard(rows...)
+	if err != nil {
+		t.Fatalf("ParseBoard error: %v", err)
+	}
+	return b
+}
+
+// chainScore places pair at p on a copy of board and returns the points.
+func chainScore(board *game.Board, pair game.Pair, p Placement) int {
+	next := board.Clone()
+	if err := next.Place(pair, p.Col, p.Rot); err != nil {
+		return -1
+	}
+	score := 0
+	for _, step := range next.Resolve() {
+		score += step.Score
+	}
+	return score
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{Easy, Normal, Hard} {
+		got, err := ParseLevel(l.String())
+		if err != nil || got != l {
+			t.Errorf("ParseLevel(%q): expected %v, got %v (%v)", l.String(), l, got, err)
+		}
+	}
+	if _, err := ParseLevel("impossible"); err != ErrUnknownLevel {
+		t.Errorf("expected ErrUnknownLevel, got %v", err)
+	}
+	if Easy.Depth() != 1 || Hard.Depth() != 3 {
+		t.Errorf("unexpected depths %d and %d", Easy.Depth(), Hard.Depth())
+	}
+}
+
+func TestPlanPopsGroup(t *testing.T) {
+	board := mustParse(t,
+		"....Y.",
+		"....R.",
+		"....R.",
+		"....R.",
+	)
+	pair := game.Pair{Main: game.Red, Sub: game.Blue}
+
+	p := Plan(board, []game.Pair{pair}, 1)
+	if chainScore(board, pair, p) == 0 {
+		t.Errorf("expected a placement that pops the reds, got %+v", p)
+	}
+}
+
+func TestPlanLooksAhead(t *testing.T) {
+	// The reds can only be popped by the second pair, and only if the
+	// first one stays off them.
+	board := mustParse(t,
+		"R.....",
+		"R.....",
+		"R.....",
+	)
+	pairs := []game.Pair{{Main: game.Green, Sub: game.Yellow}, {Main: game.Red, Sub: game.Red}}
+
+	first := Plan(board, pairs, 2)
+	next := board.Clone()
+	if err := next.Place(pairs[0], first.Col, first.Rot); err != nil {
+		t.Fatalf("Place error: %v", err)
+	}
+	next.Resolve()
+	if second := Plan(next, pairs[1:], 1); chainScore(next, pairs[1], second) == 0 {
+		t.Errorf("expected %+v then %+v to pop the reds", first, second)
+	}
+}
+
+func TestPlanAvoidsTopOut(t *testing.T) {
+	board := mustParse(t,
+		"..G...",
+		"..B...",
+		"..Y...",
+		"..G...",
+		"..B...",
+		"..Y...",
+		"..G...",
+		"..B...",
+		"..Y...",
+		"..G...",
+		"..B...",
+	)
+	pair := game.Pair{Main: game.Red, Sub: game.Red}
+
+	p := Plan(board, []game.Pair{pair}, 1)
+	next := board.Clone()
+	if err := next.Place(pair, p.Col, p.Rot); err != nil {
+		t.Fatalf("Place error: %v", err)
+	}
+	if next.At(0, game.SpawnCol) != game.Empty {
+		t.Errorf("expected %+v not to fill the spawn column", p)
+	}
+}
+
+type fixedSource struct {
+	pair game.Pair
+}
+
+func (s fixedSource) Next(game.Player) game.Pair {
+	return s.pair
+}
+
+func TestNextSteersToPlacement(t *testing.T) {
+	pair := game.Pair{Main: game.Red, Sub: game.Blue}
+	for col := 0; col < game.Cols; col++ {
+		for rot := game.RotUp; rot <= game.RotLeft; rot++ {
+			target := Placement{Col: col, Rot: rot}
+			want := game.NewBoard()
+			if err := want.Place(pair, col, rot); err != nil {
+				continue
+			}
+
+			m := game.NewMatch(fixedSource{pair})
+			for steps := 0; ; steps++ {
+				if steps > 8 {
+					t.Fatalf("%+v: piece did not lock", target)
+				}
+				res, err := m.Apply(game.Player1, Next(*m.Piece(game.Player1), target))
+				if err != nil {
+					t.Fatalf("%+v: Apply error: %v", target, err)
+				}
+				if res.Locked {
+					break
+				}
+			}
+			if got := m.Board(game.Player1).String(); got != want.String() {
+				t.Errorf("%+v: expected\n%s\ngot\n%s", target, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite", DSN(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func countMigrations(t *testing.T, conn *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil {
+		t.Fatalf("count schema_migrations: %v", err)
+	}
+	return n
+}
+
+func TestMigrate(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+
+	if err := Migrate(ctx, conn); err != nil {
+		t.Fatalf("Migrate error: %v", err)
+	}
+	want := countMigrations(t, conn)
+	if want == 0 {
+		t.Fatal("expected migrations to be recorded")
+	}
+
+	// Data survives and nothing is reapplied on the next boot.
+	if _, err := New(conn).CreateUser(ctx, CreateUserParams{Name: "migrate", PasswordHash: "x"}); err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	if err := Migrate(ctx, conn); err != nil {
+		t.Fatalf("second Migrate error: %v", err)
+	}
+	if got := countMigrations(t, conn); got != want {
+		t.Errorf("expected %d migrations, got %d", want, got)
+	}
+	if _, err := New(conn).GetUserByName(ctx, "migrate"); err != nil {
+		t.Errorf("expected user to survive, got %v", err)
+	}
+}
+
+func TestMigrateIncremental(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"0001_create.sql": {Data: []byte("CREATE TABLE things (id INTEGER PRIMARY KEY);")},
+	}
+	if err := migrate(ctx, conn, fsys); err != nil {
+		t.Fatalf("migrate error: %v", err)
+	}
+
+	fsys["0002_add_name.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE things ADD COLUMN name TEXT;")}
+	if err := migrate(ctx, conn, fsys); err != nil {
+		t.Fatalf("migrate error: %v", err)
+	}
+	if got := countMigrations(t, conn); got != 2 {
+		t.Errorf("expected 2 migrations, got %d", got)
+	}
+	if _, err := conn.Exec("INSERT INTO things (name) VALUES ('a')"); err != nil {
+		t.Errorf("expected name column, got %v", err)
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	conn := openTestDB(t)
+	fsys := fstest.MapFS{
+		"0001_ok.sql":     {Data: []byte("CREATE TABLE ok (id INTEGER);")},
+		"0002_broken.sql": {Data: []byte("CREATE TABLE half (id INTEGER); NOT SQL;")},
+	}
+
+	if err := migrate(context.Background(), conn, fsys); err == nil {
+		t.Fatal("expected error from broken migration")
+	}
+	if got := countMigrations(t, conn); got != 1 {
+		t.Errorf("expected 1 migration recorded, got %d", got)
+	}
+	var name string
+	err := conn.QueryRow("SELECT name FROM sqlite_master WHERE name = 'half'").Scan(&name)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected broken migration to be rolled back, got %v", err)
+	}
+}
+
+func TestLoadMigrationsRejectsBadNames(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"NoVersion": {"init.sql": {}},
+		"Duplicate": {"0001_a.sql": {}, "01_b.sql": {}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadMigrations(fsys); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
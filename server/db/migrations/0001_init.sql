@@ -9,6 +9,8 @@ CREATE TABLE sessions (
   id TEXT PRIMARY KEY,
   user_id INTEGER NOT NULL,
   expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  last_seen_at DATETIME NOT NULL,
   FOREIGN KEY (user_id) REFERENCES users(id)
 );
 
@@ -0,0 +1,4 @@
+-- The seed of the pair generator, so a match can be reproduced, and whether
+-- both players draw from the same pair sequence.
+ALTER TABLE rooms ADD COLUMN seed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rooms ADD COLUMN shared_queue BOOLEAN NOT NULL DEFAULT TRUE;
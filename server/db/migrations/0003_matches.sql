@@ -0,0 +1,22 @@
+CREATE TABLE matches (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  room_id INTEGER NOT NULL,
+  p1_id INTEGER NOT NULL,
+  p2_id INTEGER NOT NULL,
+  winner_id INTEGER,
+  p1_score INTEGER NOT NULL,
+  p2_score INTEGER NOT NULL,
+  p1_max_chain INTEGER NOT NULL,
+  p2_max_chain INTEGER NOT NULL,
+  seed INTEGER NOT NULL,
+  end_reason TEXT NOT NULL,
+  started_at DATETIME NOT NULL,
+  ended_at DATETIME NOT NULL,
+  FOREIGN KEY (room_id) REFERENCES rooms(id),
+  FOREIGN KEY (p1_id) REFERENCES users(id),
+  FOREIGN KEY (p2_id) REFERENCES users(id),
+  FOREIGN KEY (winner_id) REFERENCES users(id)
+);
+
+CREATE INDEX matches_p1_id ON matches (p1_id, ended_at);
+CREATE INDEX matches_p2_id ON matches (p2_id, ended_at);
@@ -0,0 +1,16 @@
+-- New players start at rating.Initial.
+ALTER TABLE users ADD COLUMN rating INTEGER NOT NULL DEFAULT 1500;
+
+CREATE TABLE rating_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  match_id INTEGER NOT NULL,
+  rating_before INTEGER NOT NULL,
+  rating_after INTEGER NOT NULL,
+  created_at DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (match_id) REFERENCES matches(id)
+);
+
+CREATE INDEX rating_history_user_id ON rating_history (user_id, created_at);
+CREATE INDEX users_rating ON users (rating);
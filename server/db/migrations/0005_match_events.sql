@@ -0,0 +1,14 @@
+-- Every input a room accepted, in order, so that a match can be replayed
+-- from its seed. player is the seat: 0 for p1, 1 for p2.
+CREATE TABLE match_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  room_id INTEGER NOT NULL,
+  seq INTEGER NOT NULL,
+  player INTEGER NOT NULL,
+  turn INTEGER NOT NULL,
+  action TEXT NOT NULL,
+  created_at DATETIME NOT NULL,
+  FOREIGN KEY (room_id) REFERENCES rooms(id)
+);
+
+CREATE UNIQUE INDEX match_events_room_seq ON match_events (room_id, seq);
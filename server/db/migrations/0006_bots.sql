@@ -0,0 +1,3 @@
+-- Bots are users that play from inside the server. They cannot sign in
+-- and are left out of ratings and the leaderboard.
+ALTER TABLE users ADD COLUMN bot BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- One-time password reset tokens, generated by an admin from the command
+-- line. Only the SHA-256 of a token is stored.
+CREATE TABLE password_resets (
+  token_hash TEXT PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
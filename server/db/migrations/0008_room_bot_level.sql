@@ -0,0 +1,3 @@
+-- The level of the bot seated as player 2, empty for rooms between two
+-- people, so that the bot can be seated again when the room is reopened.
+ALTER TABLE rooms ADD COLUMN bot_level TEXT NOT NULL DEFAULT '';
@@ -9,17 +9,64 @@ import (
 	"time"
 )
 
-type Room struct {
-	ID     int64
-	P1ID   sql.NullInt64
-	P2ID   sql.NullInt64
-	Status string
+type Match struct {
+	ID         int64
+	RoomID     int64
+	P1ID       int64
+	P2ID       int64
+	WinnerID   sql.NullInt64
+	P1Score    int64
+	P2Score    int64
+	P1MaxChain int64
+	P2MaxChain int64
+	Seed       int64
+	EndReason  string
+	StartedAt  time.Time
+	EndedAt    time.Time
 }
 
-type Session struct {
-	ID        string
+type MatchEvent struct {
+	ID        int64
+	RoomID    int64
+	Seq       int64
+	Player    int64
+	Turn      int64
+	Action    string
+	CreatedAt time.Time
+}
+
+type PasswordReset struct {
+	TokenHash string
 	UserID    int64
 	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type RatingHistory struct {
+	ID           int64
+	UserID       int64
+	MatchID      int64
+	RatingBefore int64
+	RatingAfter  int64
+	CreatedAt    time.Time
+}
+
+type Room struct {
+	ID          int64
+	P1ID        sql.NullInt64
+	P2ID        sql.NullInt64
+	Status      string
+	Seed        int64
+	SharedQueue bool
+	BotLevel    string
+}
+
+type Session struct {
+	ID         string
+	UserID     int64
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastSeenAt time.Time
 }
 
 type User struct {
@@ -27,4 +74,6 @@ type User struct {
 	Name         string
 	PasswordHash string
 	CreatedAt    sql.NullTime
+	Rating       int64
+	Bot          bool
 }
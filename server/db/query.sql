@@ -13,9 +13,9 @@ WHERE id = ? LIMIT 1;
 
 -- name: CreateSession :one
 INSERT INTO sessions (
-  id, user_id, expires_at
+  id, user_id, expires_at, created_at, last_seen_at
 ) VALUES (
-  ?, ?, ?
+  ?, ?, ?, ?, ?
 )
 RETURNING *;
 
@@ -23,6 +23,159 @@ RETURNING *;
 SELECT * FROM sessions
 WHERE id = ? LIMIT 1;
 
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = ?, expires_at = ?
+WHERE id = ?;
+
 -- name: DeleteSession :exec
 DELETE FROM sessions
 WHERE id = ?;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires_at <= ?;
+
+-- name: CreateRoom :one
+INSERT INTO rooms (
+  p1_id, status, seed, shared_queue, bot_level
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: GetRoom :one
+SELECT * FROM rooms
+WHERE id = ? LIMIT 1;
+
+-- name: ListRooms :many
+SELECT * FROM rooms
+WHERE status != 'finished'
+ORDER BY id;
+
+-- name: ListRoomsByStatus :many
+SELECT * FROM rooms
+WHERE status = ?
+ORDER BY id;
+
+-- name: ListBotRooms :many
+SELECT * FROM rooms
+WHERE bot_level != '' AND status != 'finished'
+ORDER BY id;
+
+-- name: GetActiveRoomByUser :one
+SELECT * FROM rooms
+WHERE (p1_id = ? OR p2_id = ?) AND status != 'finished'
+LIMIT 1;
+
+-- name: JoinRoom :one
+UPDATE rooms
+SET p2_id = ?, status = 'playing'
+WHERE id = ? AND status = 'waiting' AND p2_id IS NULL
+RETURNING *;
+
+-- name: UpdateRoomStatus :one
+UPDATE rooms
+SET status = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: DeleteSessionsByUserID :exec
+DELETE FROM sessions
+WHERE user_id = ?;
+
+-- name: DeleteOtherSessions :exec
+DELETE FROM sessions
+WHERE user_id = ? AND id != ?;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = ?
+WHERE id = ?;
+
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (
+  token_hash, user_id, expires_at, created_at
+) VALUES (
+  ?, ?, ?, ?
+);
+
+-- name: DeletePasswordReset :one
+DELETE FROM password_resets
+WHERE token_hash = ?
+RETURNING *;
+
+-- name: DeletePasswordResetsByUserID :exec
+DELETE FROM password_resets
+WHERE user_id = ?;
+
+-- name: CreateMatch :one
+INSERT INTO matches (
+  room_id, p1_id, p2_id, winner_id, p1_score, p2_score,
+  p1_max_chain, p2_max_chain, seed, end_reason, started_at, ended_at
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: GetMatch :one
+SELECT * FROM matches
+WHERE id = ? LIMIT 1;
+
+-- name: ListMatchesByUser :many
+SELECT * FROM matches
+WHERE p1_id = ? OR p2_id = ?
+ORDER BY ended_at DESC, id DESC
+LIMIT ? OFFSET ?;
+
+-- name: CountMatchesByUser :one
+SELECT COUNT(*) FROM matches
+WHERE p1_id = ? OR p2_id = ?;
+
+-- name: UpdateUserRating :exec
+UPDATE users
+SET rating = ?
+WHERE id = ?;
+
+-- name: CreateRatingHistory :exec
+INSERT INTO rating_history (
+  user_id, match_id, rating_before, rating_after, created_at
+) VALUES (
+  ?, ?, ?, ?, ?
+);
+
+-- name: ListLeaderboard :many
+SELECT * FROM users
+WHERE NOT bot
+ORDER BY rating DESC, id
+LIMIT ? OFFSET ?;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE NOT bot;
+
+-- name: GetRatingRank :one
+SELECT COUNT(*) + 1 FROM users
+WHERE rating > ? AND NOT bot;
+
+-- name: CreateMatchEvent :exec
+INSERT INTO match_events (
+  room_id, seq, player, turn, action, created_at
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListMatchEvents :many
+SELECT * FROM match_events
+WHERE room_id = ?
+ORDER BY seq;
+
+-- name: GetBotUser :one
+SELECT * FROM users
+WHERE bot
+ORDER BY id LIMIT 1;
+
+-- name: CreateBotUser :one
+INSERT INTO users (name, password_hash, bot)
+VALUES (?, '', TRUE)
+RETURNING *;
@@ -7,35 +7,234 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
+const countMatchesByUser = `-- name: CountMatchesByUser :one
+SELECT COUNT(*) FROM matches
+WHERE p1_id = ? OR p2_id = ?
+`
+
+type CountMatchesByUserParams struct {
+	P1ID int64
+	P2ID int64
+}
+
+func (q *Queries) CountMatchesByUser(ctx context.Context, arg CountMatchesByUserParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countMatchesByUser, arg.P1ID, arg.P2ID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE NOT bot
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createBotUser = `-- name: CreateBotUser :one
+INSERT INTO users (name, password_hash, bot)
+VALUES (?, '', TRUE)
+RETURNING id, name, password_hash, created_at, rating, bot
+`
+
+func (q *Queries) CreateBotUser(ctx context.Context, name string) (User, error) {
+	row := q.db.QueryRowContext(ctx, createBotUser, name)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.Rating,
+		&i.Bot,
+	)
+	return i, err
+}
+
+const createMatch = `-- name: CreateMatch :one
+INSERT INTO matches (
+  room_id, p1_id, p2_id, winner_id, p1_score, p2_score,
+  p1_max_chain, p2_max_chain, seed, end_reason, started_at, ended_at
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING id, room_id, p1_id, p2_id, winner_id, p1_score, p2_score, p1_max_chain, p2_max_chain, seed, end_reason, started_at, ended_at
+`
+
+type CreateMatchParams struct {
+	RoomID     int64
+	P1ID       int64
+	P2ID       int64
+	WinnerID   sql.NullInt64
+	P1Score    int64
+	P2Score    int64
+	P1MaxChain int64
+	P2MaxChain int64
+	Seed       int64
+	EndReason  string
+	StartedAt  time.Time
+	EndedAt    time.Time
+}
+
+func (q *Queries) CreateMatch(ctx context.Context, arg CreateMatchParams) (Match, error) {
+	row := q.db.QueryRowContext(ctx, createMatch, arg.RoomID, arg.P1ID, arg.P2ID, arg.WinnerID, arg.P1Score, arg.P2Score, arg.P1MaxChain, arg.P2MaxChain, arg.Seed, arg.EndReason, arg.StartedAt, arg.EndedAt)
+	var i Match
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.P1ID,
+		&i.P2ID,
+		&i.WinnerID,
+		&i.P1Score,
+		&i.P2Score,
+		&i.P1MaxChain,
+		&i.P2MaxChain,
+		&i.Seed,
+		&i.EndReason,
+		&i.StartedAt,
+		&i.EndedAt,
+	)
+	return i, err
+}
+
+const createMatchEvent = `-- name: CreateMatchEvent :exec
+INSERT INTO match_events (
+  room_id, seq, player, turn, action, created_at
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+)
+`
+
+type CreateMatchEventParams struct {
+	RoomID    int64
+	Seq       int64
+	Player    int64
+	Turn      int64
+	Action    string
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateMatchEvent(ctx context.Context, arg CreateMatchEventParams) error {
+	_, err := q.db.ExecContext(ctx, createMatchEvent, arg.RoomID, arg.Seq, arg.Player, arg.Turn, arg.Action, arg.CreatedAt)
+	return err
+}
+
+const createPasswordReset = `-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (
+  token_hash, user_id, expires_at, created_at
+) VALUES (
+  ?, ?, ?, ?
+)
+`
+
+type CreatePasswordResetParams struct {
+	TokenHash string
+	UserID    int64
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) error {
+	_, err := q.db.ExecContext(ctx, createPasswordReset, arg.TokenHash, arg.UserID, arg.ExpiresAt, arg.CreatedAt)
+	return err
+}
+
+const createRatingHistory = `-- name: CreateRatingHistory :exec
+INSERT INTO rating_history (
+  user_id, match_id, rating_before, rating_after, created_at
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+`
+
+type CreateRatingHistoryParams struct {
+	UserID       int64
+	MatchID      int64
+	RatingBefore int64
+	RatingAfter  int64
+	CreatedAt    time.Time
+}
+
+func (q *Queries) CreateRatingHistory(ctx context.Context, arg CreateRatingHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, createRatingHistory, arg.UserID, arg.MatchID, arg.RatingBefore, arg.RatingAfter, arg.CreatedAt)
+	return err
+}
+
+const createRoom = `-- name: CreateRoom :one
+INSERT INTO rooms (
+  p1_id, status, seed, shared_queue, bot_level
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+RETURNING id, p1_id, p2_id, status, seed, shared_queue, bot_level
+`
+
+type CreateRoomParams struct {
+	P1ID        sql.NullInt64
+	Status      string
+	Seed        int64
+	SharedQueue bool
+	BotLevel    string
+}
+
+func (q *Queries) CreateRoom(ctx context.Context, arg CreateRoomParams) (Room, error) {
+	row := q.db.QueryRowContext(ctx, createRoom, arg.P1ID, arg.Status, arg.Seed, arg.SharedQueue, arg.BotLevel)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.P1ID,
+		&i.P2ID,
+		&i.Status,
+		&i.Seed,
+		&i.SharedQueue,
+		&i.BotLevel,
+	)
+	return i, err
+}
+
 const createSession = `-- name: CreateSession :one
 INSERT INTO sessions (
-  id, user_id, expires_at
+  id, user_id, expires_at, created_at, last_seen_at
 ) VALUES (
-  ?, ?, ?
+  ?, ?, ?, ?, ?
 )
-RETURNING id, user_id, expires_at
+RETURNING id, user_id, expires_at, created_at, last_seen_at
 `
 
 type CreateSessionParams struct {
-	ID        string
-	UserID    int64
-	ExpiresAt time.Time
+	ID         string
+	UserID     int64
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastSeenAt time.Time
 }
 
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
-	row := q.db.QueryRowContext(ctx, createSession, arg.ID, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createSession, arg.ID, arg.UserID, arg.ExpiresAt, arg.CreatedAt, arg.LastSeenAt)
 	var i Session
-	err := row.Scan(&i.ID, &i.UserID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+	)
 	return i, err
 }
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (name, password_hash)
 VALUES (?, ?)
-RETURNING id, name, password_hash, created_at
+RETURNING id, name, password_hash, created_at, rating, bot
 `
 
 type CreateUserParams struct {
@@ -51,10 +250,68 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Name,
 		&i.PasswordHash,
 		&i.CreatedAt,
+		&i.Rating,
+		&i.Bot,
 	)
 	return i, err
 }
 
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires_at <= ?
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredSessions, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOtherSessions = `-- name: DeleteOtherSessions :exec
+DELETE FROM sessions
+WHERE user_id = ? AND id != ?
+`
+
+type DeleteOtherSessionsParams struct {
+	UserID int64
+	ID     string
+}
+
+func (q *Queries) DeleteOtherSessions(ctx context.Context, arg DeleteOtherSessionsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteOtherSessions, arg.UserID, arg.ID)
+	return err
+}
+
+const deletePasswordReset = `-- name: DeletePasswordReset :one
+DELETE FROM password_resets
+WHERE token_hash = ?
+RETURNING token_hash, user_id, expires_at, created_at
+`
+
+func (q *Queries) DeletePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	row := q.db.QueryRowContext(ctx, deletePasswordReset, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deletePasswordResetsByUserID = `-- name: DeletePasswordResetsByUserID :exec
+DELETE FROM password_resets
+WHERE user_id = ?
+`
+
+func (q *Queries) DeletePasswordResetsByUserID(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deletePasswordResetsByUserID, userID)
+	return err
+}
+
 const deleteSession = `-- name: DeleteSession :exec
 DELETE FROM sessions
 WHERE id = ?
@@ -65,20 +322,140 @@ func (q *Queries) DeleteSession(ctx context.Context, id string) error {
 	return err
 }
 
+const deleteSessionsByUserID = `-- name: DeleteSessionsByUserID :exec
+DELETE FROM sessions
+WHERE user_id = ?
+`
+
+func (q *Queries) DeleteSessionsByUserID(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteSessionsByUserID, userID)
+	return err
+}
+
+const getActiveRoomByUser = `-- name: GetActiveRoomByUser :one
+SELECT id, p1_id, p2_id, status, seed, shared_queue, bot_level FROM rooms
+WHERE (p1_id = ? OR p2_id = ?) AND status != 'finished'
+LIMIT 1
+`
+
+type GetActiveRoomByUserParams struct {
+	P1ID sql.NullInt64
+	P2ID sql.NullInt64
+}
+
+func (q *Queries) GetActiveRoomByUser(ctx context.Context, arg GetActiveRoomByUserParams) (Room, error) {
+	row := q.db.QueryRowContext(ctx, getActiveRoomByUser, arg.P1ID, arg.P2ID)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.P1ID,
+		&i.P2ID,
+		&i.Status,
+		&i.Seed,
+		&i.SharedQueue,
+		&i.BotLevel,
+	)
+	return i, err
+}
+
+const getBotUser = `-- name: GetBotUser :one
+SELECT id, name, password_hash, created_at, rating, bot FROM users
+WHERE bot
+ORDER BY id LIMIT 1
+`
+
+func (q *Queries) GetBotUser(ctx context.Context) (User, error) {
+	row := q.db.QueryRowContext(ctx, getBotUser)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.Rating,
+		&i.Bot,
+	)
+	return i, err
+}
+
+const getMatch = `-- name: GetMatch :one
+SELECT id, room_id, p1_id, p2_id, winner_id, p1_score, p2_score, p1_max_chain, p2_max_chain, seed, end_reason, started_at, ended_at FROM matches
+WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetMatch(ctx context.Context, id int64) (Match, error) {
+	row := q.db.QueryRowContext(ctx, getMatch, id)
+	var i Match
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.P1ID,
+		&i.P2ID,
+		&i.WinnerID,
+		&i.P1Score,
+		&i.P2Score,
+		&i.P1MaxChain,
+		&i.P2MaxChain,
+		&i.Seed,
+		&i.EndReason,
+		&i.StartedAt,
+		&i.EndedAt,
+	)
+	return i, err
+}
+
+const getRatingRank = `-- name: GetRatingRank :one
+SELECT COUNT(*) + 1 FROM users
+WHERE rating > ? AND NOT bot
+`
+
+func (q *Queries) GetRatingRank(ctx context.Context, rating int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getRatingRank, rating)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const getRoom = `-- name: GetRoom :one
+SELECT id, p1_id, p2_id, status, seed, shared_queue, bot_level FROM rooms
+WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetRoom(ctx context.Context, id int64) (Room, error) {
+	row := q.db.QueryRowContext(ctx, getRoom, id)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.P1ID,
+		&i.P2ID,
+		&i.Status,
+		&i.Seed,
+		&i.SharedQueue,
+		&i.BotLevel,
+	)
+	return i, err
+}
+
 const getSession = `-- name: GetSession :one
-SELECT id, user_id, expires_at FROM sessions
+SELECT id, user_id, expires_at, created_at, last_seen_at FROM sessions
 WHERE id = ? LIMIT 1
 `
 
 func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
 	row := q.db.QueryRowContext(ctx, getSession, id)
 	var i Session
-	err := row.Scan(&i.ID, &i.UserID, &i.ExpiresAt)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, name, password_hash, created_at FROM users
+SELECT id, name, password_hash, created_at, rating, bot FROM users
 WHERE id = ? LIMIT 1
 `
 
@@ -90,12 +467,14 @@ func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
 		&i.Name,
 		&i.PasswordHash,
 		&i.CreatedAt,
+		&i.Rating,
+		&i.Bot,
 	)
 	return i, err
 }
 
 const getUserByName = `-- name: GetUserByName :one
-SELECT id, name, password_hash, created_at FROM users
+SELECT id, name, password_hash, created_at, rating, bot FROM users
 WHERE name = ? LIMIT 1
 `
 
@@ -107,6 +486,352 @@ func (q *Queries) GetUserByName(ctx context.Context, name string) (User, error)
 		&i.Name,
 		&i.PasswordHash,
 		&i.CreatedAt,
+		&i.Rating,
+		&i.Bot,
 	)
 	return i, err
 }
+
+const joinRoom = `-- name: JoinRoom :one
+UPDATE rooms
+SET p2_id = ?, status = 'playing'
+WHERE id = ? AND status = 'waiting' AND p2_id IS NULL
+RETURNING id, p1_id, p2_id, status, seed, shared_queue, bot_level
+`
+
+type JoinRoomParams struct {
+	P2ID sql.NullInt64
+	ID   int64
+}
+
+func (q *Queries) JoinRoom(ctx context.Context, arg JoinRoomParams) (Room, error) {
+	row := q.db.QueryRowContext(ctx, joinRoom, arg.P2ID, arg.ID)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.P1ID,
+		&i.P2ID,
+		&i.Status,
+		&i.Seed,
+		&i.SharedQueue,
+		&i.BotLevel,
+	)
+	return i, err
+}
+
+const listBotRooms = `-- name: ListBotRooms :many
+SELECT id, p1_id, p2_id, status, seed, shared_queue, bot_level FROM rooms
+WHERE bot_level != '' AND status != 'finished'
+ORDER BY id
+`
+
+func (q *Queries) ListBotRooms(ctx context.Context) ([]Room, error) {
+	rows, err := q.db.QueryContext(ctx, listBotRooms)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Room
+	for rows.Next() {
+		var i Room
+		if err := rows.Scan(
+			&i.ID,
+			&i.P1ID,
+			&i.P2ID,
+			&i.Status,
+			&i.Seed,
+			&i.SharedQueue,
+			&i.BotLevel,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLeaderboard = `-- name: ListLeaderboard :many
+SELECT id, name, password_hash, created_at, rating, bot FROM users
+WHERE NOT bot
+ORDER BY rating DESC, id
+LIMIT ? OFFSET ?
+`
+
+type ListLeaderboardParams struct {
+	Limit  int64
+	Offset int64
+}
+
+func (q *Queries) ListLeaderboard(ctx context.Context, arg ListLeaderboardParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listLeaderboard, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.PasswordHash,
+			&i.CreatedAt,
+			&i.Rating,
+			&i.Bot,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMatchEvents = `-- name: ListMatchEvents :many
+SELECT id, room_id, seq, player, turn, action, created_at FROM match_events
+WHERE room_id = ?
+ORDER BY seq
+`
+
+func (q *Queries) ListMatchEvents(ctx context.Context, roomID int64) ([]MatchEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listMatchEvents, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MatchEvent
+	for rows.Next() {
+		var i MatchEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.Seq,
+			&i.Player,
+			&i.Turn,
+			&i.Action,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMatchesByUser = `-- name: ListMatchesByUser :many
+SELECT id, room_id, p1_id, p2_id, winner_id, p1_score, p2_score, p1_max_chain, p2_max_chain, seed, end_reason, started_at, ended_at FROM matches
+WHERE p1_id = ? OR p2_id = ?
+ORDER BY ended_at DESC, id DESC
+LIMIT ? OFFSET ?
+`
+
+type ListMatchesByUserParams struct {
+	P1ID   int64
+	P2ID   int64
+	Limit  int64
+	Offset int64
+}
+
+func (q *Queries) ListMatchesByUser(ctx context.Context, arg ListMatchesByUserParams) ([]Match, error) {
+	rows, err := q.db.QueryContext(ctx, listMatchesByUser, arg.P1ID, arg.P2ID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Match
+	for rows.Next() {
+		var i Match
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.P1ID,
+			&i.P2ID,
+			&i.WinnerID,
+			&i.P1Score,
+			&i.P2Score,
+			&i.P1MaxChain,
+			&i.P2MaxChain,
+			&i.Seed,
+			&i.EndReason,
+			&i.StartedAt,
+			&i.EndedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRooms = `-- name: ListRooms :many
+SELECT id, p1_id, p2_id, status, seed, shared_queue, bot_level FROM rooms
+WHERE status != 'finished'
+ORDER BY id
+`
+
+func (q *Queries) ListRooms(ctx context.Context) ([]Room, error) {
+	rows, err := q.db.QueryContext(ctx, listRooms)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Room
+	for rows.Next() {
+		var i Room
+		if err := rows.Scan(
+			&i.ID,
+			&i.P1ID,
+			&i.P2ID,
+			&i.Status,
+			&i.Seed,
+			&i.SharedQueue,
+			&i.BotLevel,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoomsByStatus = `-- name: ListRoomsByStatus :many
+SELECT id, p1_id, p2_id, status, seed, shared_queue, bot_level FROM rooms
+WHERE status = ?
+ORDER BY id
+`
+
+func (q *Queries) ListRoomsByStatus(ctx context.Context, status string) ([]Room, error) {
+	rows, err := q.db.QueryContext(ctx, listRoomsByStatus, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Room
+	for rows.Next() {
+		var i Room
+		if err := rows.Scan(
+			&i.ID,
+			&i.P1ID,
+			&i.P2ID,
+			&i.Status,
+			&i.Seed,
+			&i.SharedQueue,
+			&i.BotLevel,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = ?, expires_at = ?
+WHERE id = ?
+`
+
+type TouchSessionParams struct {
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	ID         string
+}
+
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchSession, arg.LastSeenAt, arg.ExpiresAt, arg.ID)
+	return err
+}
+
+const updateRoomStatus = `-- name: UpdateRoomStatus :one
+UPDATE rooms
+SET status = ?
+WHERE id = ?
+RETURNING id, p1_id, p2_id, status, seed, shared_queue, bot_level
+`
+
+type UpdateRoomStatusParams struct {
+	Status string
+	ID     int64
+}
+
+func (q *Queries) UpdateRoomStatus(ctx context.Context, arg UpdateRoomStatusParams) (Room, error) {
+	row := q.db.QueryRowContext(ctx, updateRoomStatus, arg.Status, arg.ID)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.P1ID,
+		&i.P2ID,
+		&i.Status,
+		&i.Seed,
+		&i.SharedQueue,
+		&i.BotLevel,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = ?
+WHERE id = ?
+`
+
+type UpdateUserPasswordParams struct {
+	PasswordHash string
+	ID           int64
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.PasswordHash, arg.ID)
+	return err
+}
+
+const updateUserRating = `-- name: UpdateUserRating :exec
+UPDATE users
+SET rating = ?
+WHERE id = ?
+`
+
+type UpdateUserRatingParams struct {
+	Rating int64
+	ID     int64
+}
+
+func (q *Queries) UpdateUserRating(ctx context.Context, arg UpdateUserRatingParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserRating, arg.Rating, arg.ID)
+	return err
+}
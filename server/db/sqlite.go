@@ -0,0 +1,22 @@
+package db
+
+import (
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DSN returns the modernc sqlite data source name for the database file at
+// path, with WAL journaling and a busy timeout so concurrent writers wait
+// for each other rather than failing with SQLITE_BUSY.
+func DSN(path string) string {
+	return "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+}
+
+// IsUniqueViolation reports whether err is sqlite rejecting a duplicate
+// value in a UNIQUE column.
+func IsUniqueViolation(err error) bool {
+	var serr *sqlite.Error
+	return errors.As(err, &serr) && serr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
@@ -0,0 +1,393 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Board dimensions, matching ROWS and COLS in public/script.js.
+const (
+	Rows = 12
+	Cols = 6
+)
+
+// MinGroupSize is the number of connected puyos of one colour that pop.
+const MinGroupSize = 4
+
+// Scoring tables (Puyo Puyo Tsu), shared with calculateScore in the client.
+var (
+	ChainBonus = []int{0, 8, 16, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 480, 512}
+	ColorBonus = []int{0, 0, 3, 6, 12, 24}
+	GroupBonus = []int{0, 0, 0, 0, 0, 2, 3, 4, 5, 6, 7, 10}
+)
+
+var ErrInvalidPlacement = errors.New("invalid placement")
+
+type Color uint8
+
+const (
+	Empty Color = iota
+	Red
+	Green
+	Blue
+	Yellow
+	Garbage
+)
+
+// Colors are the four playable colours, in the order of COLORS in the client.
+var Colors = []Color{Red, Green, Blue, Yellow}
+
+var colorNames = [...]string{
+	Empty:   "",
+	Red:     "red",
+	Green:   "green",
+	Blue:    "blue",
+	Yellow:  "yellow",
+	Garbage: "garbage",
+}
+
+var colorRunes = [...]byte{
+	Empty:   '.',
+	Red:     'R',
+	Green:   'G',
+	Blue:    'B',
+	Yellow:  'Y',
+	Garbage: '#',
+}
+
+func (c Color) String() string {
+	if int(c) < len(colorNames) {
+		return colorNames[c]
+	}
+	return fmt.Sprintf("Color(%d)", c)
+}
+
+// ParseColor is the inverse of Color.String; the empty string is Empty.
+func ParseColor(s string) (Color, error) {
+	for c, name := range colorNames {
+		if name == s {
+			return Color(c), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown color %q", s)
+}
+
+type Pos struct {
+	Row int
+	Col int
+}
+
+// Pair is a falling piece. Main is the pivot, Sub orbits around it.
+type Pair struct {
+	Main Color
+	Sub  Color
+}
+
+// Rotation is the direction of Sub relative to Main.
+type Rotation int
+
+const (
+	RotUp Rotation = iota
+	RotRight
+	RotDown
+	RotLeft
+)
+
+// Offset returns the row/column delta from Main to Sub.
+func (r Rotation) Offset() (int, int) {
+	switch r & 3 {
+	case RotRight:
+		return 0, 1
+	case RotDown:
+		return 1, 0
+	case RotLeft:
+		return 0, -1
+	default:
+		return -1, 0
+	}
+}
+
+type Board struct {
+	cells [Rows][Cols]Color
+}
+
+func NewBoard() *Board {
+	return &Board{}
+}
+
+// ParseBoard builds a board from text rows using R, G, B, Y, # (garbage)
+// and . (empty). Rows are aligned to the bottom of the board.
+func ParseBoard(rows ...string) (*Board, error) {
+	if len(rows) > Rows {
+		return nil, fmt.Errorf("too many rows: %d", len(rows))
+	}
+	b := NewBoard()
+	offset := Rows - len(rows)
+	for i, row := range rows {
+		if len(row) != Cols {
+			return nil, fmt.Errorf("row %d: expected %d cells, got %d", i, Cols, len(row))
+		}
+		for c := 0; c < Cols; c++ {
+			color, ok := colorFromRune(row[c])
+			if !ok {
+				return nil, fmt.Errorf("row %d: unknown cell %q", i, row[c])
+			}
+			b.cells[offset+i][c] = color
+		}
+	}
+	return b, nil
+}
+
+func colorFromRune(ch byte) (Color, bool) {
+	for c, r := range colorRunes {
+		if r == ch {
+			return Color(c), true
+		}
+	}
+	return Empty, false
+}
+
+func (b *Board) String() string {
+	var sb strings.Builder
+	for r := 0; r < Rows; r++ {
+		for c := 0; c < Cols; c++ {
+			sb.WriteByte(colorRunes[b.cells[r][c]])
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func (b *Board) Clone() *Board {
+	clone := *b
+	return &clone
+}
+
+func InBounds(r, c int) bool {
+	return r >= 0 && r < Rows && c >= 0 && c < Cols
+}
+
+func (b *Board) At(r, c int) Color {
+	if !InBounds(r, c) {
+		return Empty
+	}
+	return b.cells[r][c]
+}
+
+func (b *Board) Set(r, c int, color Color) {
+	if InBounds(r, c) {
+		b.cells[r][c] = color
+	}
+}
+
+// Cells returns a copy of the grid, row 0 being the top.
+func (b *Board) Cells() [Rows][Cols]Color {
+	return b.cells
+}
+
+// Height returns the number of occupied cells in column c.
+func (b *Board) Height(c int) int {
+	h := 0
+	for r := Rows - 1; r >= 0; r-- {
+		if b.cells[r][c] == Empty {
+			break
+		}
+		h++
+	}
+	return h
+}
+
+// Place hard-drops a pair whose Main sits in column col with the given
+// rotation. Puyos that land above the top row are lost, as in the client.
+func (b *Board) Place(p Pair, col int, rot Rotation) error {
+	dr, dc := rot.Offset()
+	subCol := col + dc
+	if col < 0 || col >= Cols || subCol < 0 || subCol >= Cols {
+		return ErrInvalidPlacement
+	}
+
+	// The lower puyo lands first so a vertical pair stacks correctly.
+	if dr > 0 {
+		b.drop(subCol, p.Sub)
+		b.drop(col, p.Main)
+	} else {
+		b.drop(col, p.Main)
+		b.drop(subCol, p.Sub)
+	}
+	return nil
+}
+
+func (b *Board) drop(c int, color Color) {
+	r := Rows - 1 - b.Height(c)
+	if r >= 0 {
+		b.cells[r][c] = color
+	}
+}
+
+// ApplyGravity lets every puyo fall to the bottom of its column and
+// reports whether anything moved.
+func (b *Board) ApplyGravity() bool {
+	dropped := false
+	for c := 0; c < Cols; c++ {
+		write := Rows - 1
+		for r := Rows - 1; r >= 0; r-- {
+			if b.cells[r][c] == Empty {
+				continue
+			}
+			if r != write {
+				b.cells[write][c] = b.cells[r][c]
+				b.cells[r][c] = Empty
+				dropped = true
+			}
+			write--
+		}
+	}
+	return dropped
+}
+
+// Group is a set of connected puyos of the same colour.
+type Group struct {
+	Color Color
+	Cells []Pos
+}
+
+// FindGroups returns every group of MinGroupSize or more connected
+// puyos. Garbage never forms a group.
+func (b *Board) FindGroups() []Group {
+	var groups []Group
+	var visited [Rows][Cols]bool
+	for r := 0; r < Rows; r++ {
+		for c := 0; c < Cols; c++ {
+			color := b.cells[r][c]
+			if color == Empty || color == Garbage || visited[r][c] {
+				continue
+			}
+			cells := b.connected(r, c, &visited)
+			if len(cells) >= MinGroupSize {
+				groups = append(groups, Group{Color: color, Cells: cells})
+			}
+		}
+	}
+	return groups
+}
+
+var directions = [4][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}}
+
+func (b *Board) connected(r, c int, visited *[Rows][Cols]bool) []Pos {
+	color := b.cells[r][c]
+	visited[r][c] = true
+	cells := []Pos{{r, c}}
+	stack := []Pos{{r, c}}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, d := range directions {
+			nr, nc := cur.Row+d[0], cur.Col+d[1]
+			if !InBounds(nr, nc) || visited[nr][nc] || b.cells[nr][nc] != color {
+				continue
+			}
+			visited[nr][nc] = true
+			cells = append(cells, Pos{nr, nc})
+			stack = append(stack, Pos{nr, nc})
+		}
+	}
+	return cells
+}
+
+// ChainStep describes one pop in a chain.
+type ChainStep struct {
+	Chain   int
+	Groups  []Group
+	Cleared int
+	Garbage int
+	Score   int
+}
+
+// Score computes the points for popping groups at the given chain count.
+func Score(chain int, groups []Group) int {
+	count := 0
+	colors := map[Color]bool{}
+	groupBonus := 0
+	for _, g := range groups {
+		count += len(g.Cells)
+		colors[g.Color] = true
+		groupBonus += groupBonusFor(len(g.Cells))
+	}
+
+	bonus := chainBonusFor(chain) + colorBonusFor(len(colors)) + groupBonus
+	if bonus == 0 {
+		bonus = 1
+	}
+	if bonus > 999 {
+		bonus = 999
+	}
+	return 10 * count * bonus
+}
+
+// ChainBonus and ColorBonus are indexed from the first chain and the first
+// colour, GroupBonus by the group size itself, as calculateScore does.
+func chainBonusFor(chain int) int {
+	if chain <= 0 {
+		return 0
+	}
+	return ChainBonus[min(chain, len(ChainBonus))-1]
+}
+
+func colorBonusFor(colors int) int {
+	if colors <= 0 {
+		return 0
+	}
+	return ColorBonus[min(colors, len(ColorBonus))-1]
+}
+
+func groupBonusFor(size int) int {
+	if size >= len(GroupBonus) {
+		return GroupBonus[len(GroupBonus)-1]
+	}
+	return GroupBonus[size]
+}
+
+// Step applies gravity and pops the groups that are then connected. It
+// returns false when nothing popped, which ends the chain.
+func (b *Board) Step(chain int) (ChainStep, bool) {
+	b.ApplyGravity()
+	groups := b.FindGroups()
+	if len(groups) == 0 {
+		return ChainStep{}, false
+	}
+
+	step := ChainStep{
+		Chain:  chain,
+		Groups: groups,
+		Score:  Score(chain, groups),
+	}
+	for _, g := range groups {
+		for _, p := range g.Cells {
+			if b.cells[p.Row][p.Col] != Empty {
+				b.cells[p.Row][p.Col] = Empty
+				step.Cleared++
+			}
+			for _, d := range directions {
+				nr, nc := p.Row+d[0], p.Col+d[1]
+				if InBounds(nr, nc) && b.cells[nr][nc] == Garbage {
+					b.cells[nr][nc] = Empty
+					step.Garbage++
+				}
+			}
+		}
+	}
+	return step, true
+}
+
+// Resolve runs the chain to completion and returns every step in order.
+func (b *Board) Resolve() []ChainStep {
+	var steps []ChainStep
+	for chain := 1; ; chain++ {
+		step, ok := b.Step(chain)
+		if !ok {
+			return steps
+		}
+		steps = append(steps, step)
+	}
+}
@@ -0,0 +1,253 @@
+package game
+
+import (
+	"testing"
+)
+
+func mustParse(t *testing.T, rows ...string) *Board {
+	t.Helper()
+	b, err := ParseBoard(rows...)
+	if err != nil {
+		t.Fatalf("ParseBoard error: %v", err)
+	}
+	return b
+}
+
+func TestConstantsMatchClient(t *testing.T) {
+	if Rows != 12 || Cols != 6 {
+		t.Errorf("expected 12x6 board, got %dx%d", Rows, Cols)
+	}
+	if len(Colors) != 4 {
+		t.Errorf("expected 4 colours, got %d", len(Colors))
+	}
+	if len(ChainBonus) != 19 || ChainBonus[1] != 8 || ChainBonus[18] != 512 {
+		t.Errorf("unexpected chain bonus table: %v", ChainBonus)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for _, c := range []Color{Empty, Red, Green, Blue, Yellow, Garbage} {
+		got, err := ParseColor(c.String())
+		if err != nil || got != c {
+			t.Errorf("ParseColor(%q): expected %v, got %v (%v)", c.String(), c, got, err)
+		}
+	}
+	if _, err := ParseColor("purple"); err == nil {
+		t.Error("expected error for an unknown colour")
+	}
+}
+
+func TestParseBoardRoundTrip(t *testing.T) {
+	b := mustParse(t,
+		"R.....",
+		"#GBY..",
+	)
+	want := "" +
+		"......\n......\n......\n......\n......\n......\n" +
+		"......\n......\n......\n......\n" +
+		"R.....\n" +
+		"#GBY..\n"
+	if got := b.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	if _, err := ParseBoard("RRR"); err == nil {
+		t.Error("expected error for short row")
+	}
+	if _, err := ParseBoard("RRRRRX"); err == nil {
+		t.Error("expected error for unknown cell")
+	}
+}
+
+func TestPlace(t *testing.T) {
+	tests := []struct {
+		name string
+		col  int
+		rot  Rotation
+		want []string
+	}{
+		{"Up", 2, RotUp, []string{"..G...", "..R...", "..B..."}},
+		{"Down", 2, RotDown, []string{"..R...", "..G...", "..B..."}},
+		{"Right", 2, RotRight, []string{"..R...", "..BG.."}},
+		{"Left", 2, RotLeft, []string{"..R...", ".GB..."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := mustParse(t, "..B...")
+			if err := b.Place(Pair{Main: Red, Sub: Green}, tt.col, tt.rot); err != nil {
+				t.Fatalf("Place error: %v", err)
+			}
+			want := mustParse(t, tt.want...)
+			if b.String() != want.String() {
+				t.Errorf("expected\n%s\ngot\n%s", want, b)
+			}
+		})
+	}
+
+	t.Run("OutOfBounds", func(t *testing.T) {
+		b := NewBoard()
+		if err := b.Place(Pair{Main: Red, Sub: Green}, 0, RotLeft); err != ErrInvalidPlacement {
+			t.Errorf("expected ErrInvalidPlacement, got %v", err)
+		}
+		if err := b.Place(Pair{Main: Red, Sub: Green}, Cols, RotUp); err != ErrInvalidPlacement {
+			t.Errorf("expected ErrInvalidPlacement, got %v", err)
+		}
+	})
+
+	t.Run("FullColumnLosesPuyos", func(t *testing.T) {
+		rows := make([]string, Rows)
+		for i := range rows {
+			rows[i] = "B....."
+		}
+		b := mustParse(t, rows...)
+		if err := b.Place(Pair{Main: Red, Sub: Green}, 0, RotUp); err != nil {
+			t.Fatalf("Place error: %v", err)
+		}
+		if b.At(0, 0) != Blue {
+			t.Errorf("expected column to stay unchanged, got %v at top", b.At(0, 0))
+		}
+	})
+}
+
+func TestApplyGravity(t *testing.T) {
+	b := mustParse(t,
+		"R.G...",
+		"......",
+		".B....",
+		"......",
+	)
+	if !b.ApplyGravity() {
+		t.Fatal("expected puyos to fall")
+	}
+	want := mustParse(t, "RBG...")
+	if b.String() != want.String() {
+		t.Errorf("expected\n%s\ngot\n%s", want, b)
+	}
+	if b.ApplyGravity() {
+		t.Error("expected settled board to stay still")
+	}
+}
+
+func TestFindGroups(t *testing.T) {
+	b := mustParse(t,
+		"R..G..",
+		"R.GG..",
+		"RR.G##",
+		"BBB###",
+	)
+	groups := b.FindGroups()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	sizes := map[Color]int{}
+	for _, g := range groups {
+		sizes[g.Color] = len(g.Cells)
+	}
+	if sizes[Red] != 4 || sizes[Green] != 4 {
+		t.Errorf("unexpected group sizes: %v", sizes)
+	}
+}
+
+func TestScore(t *testing.T) {
+	group := func(color Color, n int) Group {
+		return Group{Color: color, Cells: make([]Pos, n)}
+	}
+
+	tests := []struct {
+		name   string
+		chain  int
+		groups []Group
+		want   int
+	}{
+		{"Single", 1, []Group{group(Red, 4)}, 40},
+		{"SecondChain", 2, []Group{group(Red, 4)}, 320},
+		// Two colours earn no bonus, as in the client's COLOR_BONUS.
+		{"TwoColors", 1, []Group{group(Red, 4), group(Blue, 4)}, 80},
+		{"ThreeColors", 1, []Group{group(Red, 4), group(Green, 4), group(Blue, 4)}, 10 * 12 * 3},
+		{"FourColors", 1, []Group{group(Red, 4), group(Green, 4), group(Blue, 4), group(Yellow, 4)}, 10 * 16 * 6},
+		{"GroupOfFive", 1, []Group{group(Red, 5)}, 100},
+		{"GroupOfEleven", 1, []Group{group(Red, 11)}, 1100},
+		{"GroupOfTwelve", 1, []Group{group(Red, 12)}, 1200},
+		{"LongChainCapped", 30, []Group{group(Red, 4)}, 4 * 10 * 512},
+		{"MaxBonus", 19, []Group{group(Red, 11), group(Green, 11), group(Blue, 11), group(Yellow, 11)}, 10 * 44 * 558},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.chain, tt.groups); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveChain(t *testing.T) {
+	b := mustParse(t,
+		"G.....",
+		"R.....",
+		"RG....",
+		"RG....",
+		"RG#...",
+	)
+
+	steps := b.Resolve()
+	if len(steps) != 2 {
+		t.Fatalf("expected 2-chain, got %d steps:\n%s", len(steps), b)
+	}
+	if steps[0].Chain != 1 || steps[0].Cleared != 4 || steps[0].Score != 40 {
+		t.Errorf("unexpected first step: %+v", steps[0])
+	}
+	if steps[1].Chain != 2 || steps[1].Cleared != 4 || steps[1].Score != 320 {
+		t.Errorf("unexpected second step: %+v", steps[1])
+	}
+	if steps[1].Garbage != 1 {
+		t.Errorf("expected 1 garbage cleared in step 2, got %d", steps[1].Garbage)
+	}
+	if b.String() != NewBoard().String() {
+		t.Errorf("expected empty board, got\n%s", b)
+	}
+}
+
+func TestStepClearsAdjacentGarbage(t *testing.T) {
+	b := mustParse(t,
+		"#.....",
+		"RRRR#.",
+		"######",
+	)
+	step, ok := b.Step(1)
+	if !ok {
+		t.Fatal("expected a pop")
+	}
+	if step.Cleared != 4 || step.Garbage != 6 {
+		t.Errorf("expected 4 cleared and 6 garbage, got %+v", step)
+	}
+	b.ApplyGravity()
+	want := mustParse(t, "....##")
+	if b.String() != want.String() {
+		t.Errorf("expected\n%s\ngot\n%s", want, b)
+	}
+}
+
+func TestResolveDeterministic(t *testing.T) {
+	rows := []string{
+		"BY....",
+		"RBY...",
+		"RRBY..",
+		"GGGBY.",
+	}
+	a := mustParse(t, rows...)
+	b := mustParse(t, rows...)
+	sa, sb := a.Resolve(), b.Resolve()
+	if len(sa) != len(sb) {
+		t.Fatalf("expected same chain length, got %d and %d", len(sa), len(sb))
+	}
+	for i := range sa {
+		if sa[i].Score != sb[i].Score {
+			t.Errorf("step %d: scores differ: %d vs %d", i, sa[i].Score, sb[i].Score)
+		}
+	}
+	if a.String() != b.String() {
+		t.Error("expected identical final boards")
+	}
+}
@@ -0,0 +1,123 @@
+package game
+
+import "time"
+
+// TimeLimits bounds how long a player may take. A zero duration disables
+// that limit.
+type TimeLimits struct {
+	// Turn is the time a player has for a whole turn.
+	Turn time.Duration
+	// Move is the time a player has to place each pair.
+	Move time.Duration
+	// MaxTimeouts is how many timeouts in a row lose the match. Zero
+	// never forfeits.
+	MaxTimeouts int
+}
+
+func (l TimeLimits) Enabled() bool {
+	return l.Turn > 0 || l.Move > 0
+}
+
+// Clock enforces TimeLimits on a match. The caller passes the current time
+// to every method, which keeps the clock deterministic under test. It is
+// not safe for concurrent use.
+type Clock struct {
+	limits    TimeLimits
+	started   bool
+	turnStart time.Time
+	moveStart time.Time
+	timeouts  [2]int
+}
+
+func NewClock(limits TimeLimits) *Clock {
+	return &Clock{limits: limits}
+}
+
+// Start starts the clock of the current turn. Until then there is no
+// deadline.
+func (c *Clock) Start(now time.Time) {
+	c.started = true
+	c.turnStart = now
+	c.moveStart = now
+}
+
+func (c *Clock) Started() bool {
+	return c.started
+}
+
+// Deadline returns when the player on turn runs out of time, or false if
+// no limit applies.
+func (c *Clock) Deadline(m *Match) (time.Time, bool) {
+	if !c.started || m.Over() || !c.limits.Enabled() {
+		return time.Time{}, false
+	}
+	var deadline time.Time
+	if c.limits.Turn > 0 {
+		deadline = c.turnStart.Add(c.limits.Turn)
+	}
+	if c.limits.Move > 0 {
+		if move := c.moveStart.Add(c.limits.Move); deadline.IsZero() || move.Before(deadline) {
+			deadline = move
+		}
+	}
+	return deadline, true
+}
+
+// Timeouts returns how many times in a row p has run out of time.
+func (c *Clock) Timeouts(p Player) int {
+	return c.timeouts[p]
+}
+
+// Played records the result of an action p took at now. Placing a pair
+// clears p's run of timeouts.
+func (c *Clock) Played(p Player, res Result, now time.Time) {
+	if res.Locked {
+		c.timeouts[p] = 0
+	}
+	c.advance(res, now)
+}
+
+// Expire plays out a timeout if the deadline has passed at now. The pair
+// of the player on turn is hard dropped where it is; if the turn limit
+// ran out, every pair left in the turn is. It returns the player who
+// timed out, the results of the drops in order, and whether that player
+// has now reached MaxTimeouts and should forfeit.
+//
+// The drops are made with apply, which is m.Apply or a wrapper that
+// stores each action before applying it. If apply fails the timeout is
+// not counted and the drops made so far are returned with the error.
+func (c *Clock) Expire(m *Match, now time.Time, apply func(Player, Action) (Result, error)) (Player, []Result, bool, error) {
+	p := m.Turn()
+	deadline, ok := c.Deadline(m)
+	if !ok || now.Before(deadline) {
+		return p, nil, false, nil
+	}
+
+	turnOver := c.limits.Turn > 0 && !now.Before(c.turnStart.Add(c.limits.Turn))
+	var results []Result
+	for {
+		res, err := apply(p, ActionHardDrop)
+		if err != nil {
+			return p, results, false, err
+		}
+		results = append(results, res)
+		c.advance(res, now)
+		if !turnOver || res.TurnChanged || res.GameOver {
+			break
+		}
+	}
+	c.timeouts[p]++
+	forfeit := c.limits.MaxTimeouts > 0 && c.timeouts[p] >= c.limits.MaxTimeouts && !m.Over()
+	return p, results, forfeit, nil
+}
+
+// advance restarts the turn clock when the turn changed and the move
+// clock when a new pair spawned.
+func (c *Clock) advance(res Result, now time.Time) {
+	if res.TurnChanged {
+		c.turnStart = now
+	}
+	if res.Locked {
+		c.moveStart = now
+	}
+}
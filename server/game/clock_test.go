@@ -0,0 +1,139 @@
+package game
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var clockStart = time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+
+func TestClockDeadline(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green})
+
+	tests := []struct {
+		name   string
+		limits TimeLimits
+		want   time.Duration
+		ok     bool
+	}{
+		{"Disabled", TimeLimits{}, 0, false},
+		{"Turn", TimeLimits{Turn: 30 * time.Second}, 30 * time.Second, true},
+		{"Move", TimeLimits{Move: 10 * time.Second}, 10 * time.Second, true},
+		{"Earliest", TimeLimits{Turn: 8 * time.Second, Move: 10 * time.Second}, 8 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClock(tt.limits)
+			if _, ok := c.Deadline(m); ok {
+				t.Error("expected no deadline before Start")
+			}
+			c.Start(clockStart)
+			deadline, ok := c.Deadline(m)
+			if ok != tt.ok || (ok && !deadline.Equal(clockStart.Add(tt.want))) {
+				t.Errorf("expected %v (%v), got %v (%v)", clockStart.Add(tt.want), tt.ok, deadline, ok)
+			}
+		})
+	}
+}
+
+func TestClockMoveTimeout(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+	c := NewClock(TimeLimits{Move: 10 * time.Second})
+	c.Start(clockStart)
+
+	if _, results, _, _ := c.Expire(m, clockStart.Add(9*time.Second), m.Apply); results != nil {
+		t.Fatalf("expected nothing before the deadline, got %+v", results)
+	}
+
+	p, results, forfeit, err := c.Expire(m, clockStart.Add(10*time.Second), m.Apply)
+	if err != nil {
+		t.Fatalf("Expire error: %v", err)
+	}
+	if p != Player1 || len(results) != 1 || !results[0].Locked || forfeit {
+		t.Fatalf("expected one drop for p1, got %v %+v %v", p, results, forfeit)
+	}
+	if m.Turn() != Player1 || m.MovesLeft(Player1) != TurnMoves-1 {
+		t.Errorf("expected p1 to keep the rest of the turn, got %v with %d moves", m.Turn(), m.MovesLeft(Player1))
+	}
+	deadline, _ := c.Deadline(m)
+	if !deadline.Equal(clockStart.Add(20 * time.Second)) {
+		t.Errorf("expected the move clock to restart, got %v", deadline)
+	}
+}
+
+func TestClockTurnTimeout(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+	c := NewClock(TimeLimits{Turn: 30 * time.Second})
+	c.Start(clockStart)
+
+	p, results, _, err := c.Expire(m, clockStart.Add(30*time.Second), m.Apply)
+	if err != nil {
+		t.Fatalf("Expire error: %v", err)
+	}
+	if p != Player1 || len(results) != TurnMoves || !results[len(results)-1].TurnChanged {
+		t.Fatalf("expected p1's whole turn to be dropped, got %v %+v", p, results)
+	}
+	if m.Turn() != Player2 {
+		t.Errorf("expected p2 on turn, got %v", m.Turn())
+	}
+	deadline, _ := c.Deadline(m)
+	if !deadline.Equal(clockStart.Add(60 * time.Second)) {
+		t.Errorf("expected p2's clock to start at the timeout, got %v", deadline)
+	}
+}
+
+func TestClockExpireApplyFails(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+	c := NewClock(TimeLimits{Move: 10 * time.Second})
+	c.Start(clockStart)
+
+	failed := errors.New("not stored")
+	_, results, _, err := c.Expire(m, clockStart.Add(10*time.Second), func(Player, Action) (Result, error) {
+		return Result{}, failed
+	})
+	if err != failed || results != nil {
+		t.Fatalf("expected the apply error and no drops, got %+v (%v)", results, err)
+	}
+	if c.Timeouts(Player1) != 0 || m.MovesLeft(Player1) != TurnMoves {
+		t.Errorf("expected nothing to change, got %d timeouts and %d moves", c.Timeouts(Player1), m.MovesLeft(Player1))
+	}
+	if deadline, _ := c.Deadline(m); !deadline.Equal(clockStart.Add(10 * time.Second)) {
+		t.Errorf("expected the deadline to stand for a retry, got %v", deadline)
+	}
+}
+
+func TestClockForfeit(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+	c := NewClock(TimeLimits{Move: time.Second, MaxTimeouts: 2})
+	now := clockStart
+	c.Start(now)
+
+	now = now.Add(time.Second)
+	if _, _, forfeit, _ := c.Expire(m, now, m.Apply); forfeit {
+		t.Fatal("expected no forfeit after one timeout")
+	}
+	if c.Timeouts(Player1) != 1 {
+		t.Errorf("expected 1 timeout, got %d", c.Timeouts(Player1))
+	}
+
+	t.Run("PlacingResets", func(t *testing.T) {
+		m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+		c := NewClock(TimeLimits{Move: time.Second, MaxTimeouts: 2})
+		c.Start(clockStart)
+		c.Expire(m, clockStart.Add(time.Second), m.Apply)
+		c.Played(Player1, mustApply(t, m, Player1, ActionHardDrop), clockStart.Add(1500*time.Millisecond))
+		if c.Timeouts(Player1) != 0 {
+			t.Errorf("expected placing a pair to reset timeouts, got %d", c.Timeouts(Player1))
+		}
+	})
+
+	now = now.Add(time.Second)
+	p, _, forfeit, err := c.Expire(m, now, m.Apply)
+	if err != nil {
+		t.Fatalf("Expire error: %v", err)
+	}
+	if p != Player1 || !forfeit {
+		t.Errorf("expected p1 to forfeit after 2 timeouts, got %v %v", p, forfeit)
+	}
+}
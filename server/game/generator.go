@@ -0,0 +1,85 @@
+package game
+
+import "math/rand/v2"
+
+// PreviewLen is how many upcoming pairs a player can see: next and
+// next-next.
+const PreviewLen = 2
+
+// Generator is a seeded PairSource. The same seed always yields the same
+// pairs, so a match can be reproduced from its seed and inputs.
+//
+// With a shared queue both players draw from one sequence, each at their
+// own pace, as in competitive Puyo. Otherwise each player gets an
+// independent sequence derived from the seed.
+type Generator struct {
+	seed     uint64
+	shared   bool
+	streams  [2]*pairStream
+	next     [2]int
+	nuisance *rand.Rand
+}
+
+// pairStream is a lazily extended, deterministic pair sequence.
+type pairStream struct {
+	rng   *rand.Rand
+	pairs []Pair
+}
+
+func newPairStream(seed, stream uint64) *pairStream {
+	return &pairStream{rng: rand.New(rand.NewPCG(seed, stream))}
+}
+
+func (s *pairStream) at(i int) Pair {
+	for len(s.pairs) <= i {
+		s.pairs = append(s.pairs, Pair{
+			Main: Colors[s.rng.IntN(len(Colors))],
+			Sub:  Colors[s.rng.IntN(len(Colors))],
+		})
+	}
+	return s.pairs[i]
+}
+
+func NewGenerator(seed uint64, shared bool) *Generator {
+	g := &Generator{
+		seed:     seed,
+		shared:   shared,
+		nuisance: rand.New(rand.NewPCG(seed, 2)),
+	}
+	g.streams[Player1] = newPairStream(seed, 0)
+	if shared {
+		g.streams[Player2] = g.streams[Player1]
+	} else {
+		g.streams[Player2] = newPairStream(seed, 1)
+	}
+	return g
+}
+
+func (g *Generator) Seed() uint64 {
+	return g.seed
+}
+
+func (g *Generator) Shared() bool {
+	return g.shared
+}
+
+func (g *Generator) Next(p Player) Pair {
+	pair := g.streams[p].at(g.next[p])
+	g.next[p]++
+	return pair
+}
+
+// Peek returns the next n pairs p will receive without consuming them.
+func (g *Generator) Peek(p Player, n int) []Pair {
+	pairs := make([]Pair, n)
+	for i := range pairs {
+		pairs[i] = g.streams[p].at(g.next[p] + i)
+	}
+	return pairs
+}
+
+// PickColumns returns n distinct columns, n <= Cols, for loose nuisance.
+// Draws come from their own stream so they do not disturb the pairs.
+func (g *Generator) PickColumns(n int) []int {
+	return g.nuisance.Perm(Cols)[:n]
+}
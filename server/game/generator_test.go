@@ -0,0 +1,81 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func draw(g *Generator, p Player, n int) []Pair {
+	pairs := make([]Pair, n)
+	for i := range pairs {
+		pairs[i] = g.Next(p)
+	}
+	return pairs
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	a := draw(NewGenerator(42, true), Player1, 50)
+	b := draw(NewGenerator(42, true), Player1, 50)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("expected the same seed to produce the same pairs")
+	}
+
+	c := draw(NewGenerator(43, true), Player1, 50)
+	if reflect.DeepEqual(a, c) {
+		t.Error("expected different seeds to produce different pairs")
+	}
+
+	for i, pair := range a {
+		if pair.Main < Red || pair.Main > Yellow || pair.Sub < Red || pair.Sub > Yellow {
+			t.Fatalf("pair %d has a non-playable colour: %+v", i, pair)
+		}
+	}
+}
+
+func TestGeneratorShared(t *testing.T) {
+	g := NewGenerator(7, true)
+
+	// Players draw at their own pace but see the same sequence.
+	p1 := draw(g, Player1, 5)
+	p2 := draw(g, Player2, 3)
+	p2 = append(p2, draw(g, Player2, 2)...)
+	if !reflect.DeepEqual(p1, p2) {
+		t.Errorf("expected identical sequences, got %v and %v", p1, p2)
+	}
+}
+
+func TestGeneratorIndependent(t *testing.T) {
+	g := NewGenerator(7, false)
+	if reflect.DeepEqual(draw(g, Player1, 20), draw(g, Player2, 20)) {
+		t.Error("expected independent sequences for each player")
+	}
+}
+
+func TestGeneratorPeek(t *testing.T) {
+	g := NewGenerator(99, true)
+	preview := g.Peek(Player1, PreviewLen)
+	if got := draw(g, Player1, PreviewLen); !reflect.DeepEqual(preview, got) {
+		t.Errorf("expected Peek %v to match the drawn pairs %v", preview, got)
+	}
+}
+
+func TestMatchPreview(t *testing.T) {
+	reference := NewGenerator(5, true)
+	want := draw(reference, Player1, 1+PreviewLen)
+
+	m := NewMatch(NewGenerator(5, true))
+	if m.Piece(Player1).Pair != want[0] {
+		t.Errorf("expected current pair %v, got %v", want[0], m.Piece(Player1).Pair)
+	}
+	if got := m.Preview(Player1); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("expected preview %v, got %v", want[1:], got)
+	}
+	// p2 has not drawn yet, so its preview starts at the first pair.
+	if got := m.Preview(Player2); !reflect.DeepEqual(got, want[:PreviewLen]) {
+		t.Errorf("expected p2 preview %v, got %v", want[:PreviewLen], got)
+	}
+
+	if newTestMatch(Pair{Red, Green}).Preview(Player1) != nil {
+		t.Error("expected no preview from a source without Peek")
+	}
+}
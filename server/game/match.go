@@ -0,0 +1,431 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Turn budget, matching TURN_MOVES and CHAIN_MOVES in public/script.js.
+const (
+	TurnMoves  = 2
+	ChainMoves = 2
+)
+
+// A new pair spawns with Main just above the grid in this column. The game
+// is lost once the cell below it is occupied.
+const (
+	SpawnRow = -1
+	SpawnCol = 2
+)
+
+var (
+	ErrNotYourTurn   = errors.New("not your turn")
+	ErrNoMovesLeft   = errors.New("no moves left")
+	ErrGameOver      = errors.New("game over")
+	ErrUnknownAction = errors.New("unknown action")
+	ErrUnknownPlayer = errors.New("unknown player")
+)
+
+type Player int
+
+const (
+	Player1 Player = iota
+	Player2
+)
+
+func (p Player) Opponent() Player {
+	return 1 - p
+}
+
+func (p Player) Valid() bool {
+	return p == Player1 || p == Player2
+}
+
+func (p Player) String() string {
+	switch p {
+	case Player1:
+		return "p1"
+	case Player2:
+		return "p2"
+	}
+	return fmt.Sprintf("Player(%d)", int(p))
+}
+
+type Action int
+
+const (
+	ActionLeft Action = iota
+	ActionRight
+	ActionRotateCW
+	ActionRotateCCW
+	ActionDrop
+	ActionHardDrop
+)
+
+var actionNames = [...]string{
+	ActionLeft:      "left",
+	ActionRight:     "right",
+	ActionRotateCW:  "rotate_cw",
+	ActionRotateCCW: "rotate_ccw",
+	ActionDrop:      "drop",
+	ActionHardDrop:  "hard_drop",
+}
+
+func (a Action) String() string {
+	if a >= 0 && int(a) < len(actionNames) {
+		return actionNames[a]
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+func ParseAction(s string) (Action, error) {
+	for a, name := range actionNames {
+		if name == s {
+			return Action(a), nil
+		}
+	}
+	return 0, ErrUnknownAction
+}
+
+// Piece is the pair a player is currently steering. Row and Col locate
+// Main; rows above the grid are negative.
+type Piece struct {
+	Pair Pair
+	Row  int
+	Col  int
+	Rot  Rotation
+}
+
+func (p Piece) SubPos() (int, int) {
+	dr, dc := p.Rot.Offset()
+	return p.Row + dr, p.Col + dc
+}
+
+// PairSource supplies the next pair a player receives.
+type PairSource interface {
+	Next(p Player) Pair
+}
+
+// Previewer is implemented by sources that can show upcoming pairs without
+// consuming them.
+type Previewer interface {
+	Peek(p Player, n int) []Pair
+}
+
+// ColumnPicker is implemented by sources that choose the columns loose
+// nuisance falls into. Without one, loose nuisance fills columns from the
+// left.
+type ColumnPicker interface {
+	PickColumns(n int) []int
+}
+
+// Result reports what happened as a consequence of an action.
+type Result struct {
+	Locked      bool
+	Chain       []ChainStep
+	TurnChanged bool
+	GameOver    bool
+	// Nuisance is set when the turn ended with nuisance generated or
+	// pending.
+	Nuisance *NuisanceResult
+}
+
+// Match is the server-side turn state machine. It is not safe for
+// concurrent use.
+type Match struct {
+	source    PairSource
+	boards    [2]*Board
+	pieces    [2]*Piece
+	movesLeft [2]int
+	score     [2]int
+	maxChain  [2]int
+	pending   [2]int
+	remainder [2]int
+	turn      Player
+	turnCount int
+	over      bool
+	winner    Player
+}
+
+func NewMatch(source PairSource) *Match {
+	m := &Match{
+		source:    source,
+		boards:    [2]*Board{NewBoard(), NewBoard()},
+		movesLeft: [2]int{TurnMoves, 0},
+		turn:      Player1,
+		turnCount: 1,
+	}
+	m.spawn()
+	return m
+}
+
+func (m *Match) Turn() Player {
+	return m.turn
+}
+
+// TurnCount is the 1-based number of the current turn.
+func (m *Match) TurnCount() int {
+	return m.turnCount
+}
+
+func (m *Match) MovesLeft(p Player) int {
+	return m.movesLeft[p]
+}
+
+func (m *Match) Board(p Player) *Board {
+	return m.boards[p].Clone()
+}
+
+// Piece returns the active piece of p, or nil when p is not steering one.
+func (m *Match) Piece(p Player) *Piece {
+	if m.pieces[p] == nil {
+		return nil
+	}
+	piece := *m.pieces[p]
+	return &piece
+}
+
+// Preview returns the next PreviewLen pairs p will receive after the
+// current piece, or nil if the source cannot preview.
+func (m *Match) Preview(p Player) []Pair {
+	previewer, ok := m.source.(Previewer)
+	if !ok {
+		return nil
+	}
+	return previewer.Peek(p, PreviewLen)
+}
+
+func (m *Match) Score(p Player) int {
+	return m.score[p]
+}
+
+// Pending returns the nuisance waiting to fall on p.
+func (m *Match) Pending(p Player) int {
+	return m.pending[p]
+}
+
+func (m *Match) MaxChain(p Player) int {
+	return m.maxChain[p]
+}
+
+func (m *Match) Over() bool {
+	return m.over
+}
+
+// Winner is only meaningful once Over reports true.
+func (m *Match) Winner() Player {
+	return m.winner
+}
+
+// Check returns the error Apply would return for p taking a, without
+// taking it.
+func (m *Match) Check(p Player, a Action) error {
+	switch {
+	case !p.Valid():
+		return ErrUnknownPlayer
+	case m.over:
+		return ErrGameOver
+	case p != m.turn:
+		return ErrNotYourTurn
+	case m.movesLeft[p] <= 0 || m.pieces[p] == nil:
+		return ErrNoMovesLeft
+	case a < 0 || int(a) >= len(actionNames):
+		return ErrUnknownAction
+	}
+	return nil
+}
+
+// Apply performs an action on behalf of p.
+func (m *Match) Apply(p Player, a Action) (Result, error) {
+	if err := m.Check(p, a); err != nil {
+		return Result{}, err
+	}
+
+	board := m.boards[p]
+	piece := m.pieces[p]
+	switch a {
+	case ActionLeft:
+		m.shift(board, piece, -1)
+	case ActionRight:
+		m.shift(board, piece, 1)
+	case ActionRotateCW:
+		m.rotate(board, piece, 1)
+	case ActionRotateCCW:
+		m.rotate(board, piece, -1)
+	case ActionDrop:
+		if !m.fall(board, piece) {
+			return m.lock(), nil
+		}
+	case ActionHardDrop:
+		m.hardDrop(board, piece)
+		return m.lock(), nil
+	}
+	return Result{}, nil
+}
+
+// Forfeit ends the match with p losing, as when p abandons it.
+func (m *Match) Forfeit(p Player) error {
+	if !p.Valid() {
+		return ErrUnknownPlayer
+	}
+	if m.over {
+		return ErrGameOver
+	}
+	m.over = true
+	m.winner = p.Opponent()
+	return nil
+}
+
+func canOccupy(b *Board, r, c int) bool {
+	return c >= 0 && c < Cols && r < Rows && (r < 0 || b.At(r, c) == Empty)
+}
+
+func fits(b *Board, piece Piece) bool {
+	sr, sc := piece.SubPos()
+	return canOccupy(b, piece.Row, piece.Col) && canOccupy(b, sr, sc)
+}
+
+func (m *Match) shift(b *Board, piece *Piece, dir int) {
+	moved := *piece
+	moved.Col += dir
+	if fits(b, moved) {
+		*piece = moved
+	}
+}
+
+// rotateKicks are tried in order, as in the client: in place, right, left,
+// down, then up (floor kick).
+var rotateKicks = [][2]int{{0, 0}, {0, 1}, {0, -1}, {1, 0}, {-1, 0}}
+
+func (m *Match) rotate(b *Board, piece *Piece, dir int) {
+	for _, k := range rotateKicks {
+		rotated := *piece
+		rotated.Rot = (piece.Rot + Rotation(dir) + 4) % 4
+		rotated.Row += k[0]
+		rotated.Col += k[1]
+		if fits(b, rotated) {
+			*piece = rotated
+			return
+		}
+	}
+}
+
+func (m *Match) fall(b *Board, piece *Piece) bool {
+	moved := *piece
+	moved.Row++
+	if !fits(b, moved) {
+		return false
+	}
+	*piece = moved
+	return true
+}
+
+func (m *Match) hardDrop(b *Board, piece *Piece) {
+	for m.fall(b, piece) {
+	}
+}
+
+// lock settles the active piece and runs the end-of-move logic from
+// resolveMatches in the client.
+func (m *Match) lock() Result {
+	p := m.turn
+	board := m.boards[p]
+	piece := m.pieces[p]
+	sr, sc := piece.SubPos()
+	board.Set(piece.Row, piece.Col, piece.Pair.Main)
+	board.Set(sr, sc, piece.Pair.Sub)
+	m.pieces[p] = nil
+
+	res := Result{Locked: true}
+	res.Chain = board.Resolve()
+	for _, step := range res.Chain {
+		m.score[p] += step.Score
+	}
+	m.maxChain[p] = max(m.maxChain[p], len(res.Chain))
+
+	m.movesLeft[p]--
+	turnEnd := len(res.Chain) > 0 || m.movesLeft[p] == 0
+	if turnEnd {
+		m.movesLeft[p.Opponent()] += len(res.Chain) * ChainMoves
+		res.Nuisance = m.settleNuisance(p, res.Chain)
+	}
+
+	if m.checkGameOver() {
+		res.GameOver = true
+		return res
+	}
+	if turnEnd {
+		m.switchTurn()
+		res.TurnChanged = true
+	} else {
+		m.spawn()
+	}
+	return res
+}
+
+// settleNuisance runs at the end of p's turn, as handleNuisance does in
+// the client.
+func (m *Match) settleNuisance(p Player, chain []ChainStep) *NuisanceResult {
+	points := 0
+	for _, step := range chain {
+		points += step.Score
+	}
+	var generated int
+	generated, m.remainder[p] = ConvertScore(points, m.remainder[p])
+	if generated == 0 && m.pending[p] == 0 {
+		return nil
+	}
+
+	res := &NuisanceResult{Generated: generated}
+	remaining, sent := Offset(generated, m.pending[p])
+	res.Offset = m.pending[p] - remaining
+	res.Sent = sent
+	m.pending[p] = remaining
+	m.pending[p.Opponent()] += sent
+
+	if m.pending[p] > 0 {
+		n := min(m.pending[p], MaxNuisanceDrop)
+		m.pending[p] -= n
+		m.boards[p].DropNuisance(n/Cols, m.nuisanceColumns(n%Cols))
+		res.Dropped = n
+	}
+	return res
+}
+
+func (m *Match) nuisanceColumns(n int) []int {
+	if picker, ok := m.source.(ColumnPicker); ok {
+		return picker.PickColumns(n)
+	}
+	columns := make([]int, n)
+	for i := range columns {
+		columns[i] = i
+	}
+	return columns
+}
+
+func (m *Match) checkGameOver() bool {
+	for _, p := range []Player{Player1, Player2} {
+		if m.boards[p].At(0, SpawnCol) != Empty {
+			m.over = true
+			m.winner = p.Opponent()
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Match) switchTurn() {
+	m.turn = m.turn.Opponent()
+	m.turnCount++
+	m.movesLeft[m.turn] += TurnMoves
+	m.spawn()
+}
+
+func (m *Match) spawn() {
+	m.pieces[m.turn] = &Piece{
+		Pair: m.source.Next(m.turn),
+		Row:  SpawnRow,
+		Col:  SpawnCol,
+		Rot:  RotUp,
+	}
+}
@@ -0,0 +1,212 @@
+package game
+
+import (
+	"testing"
+)
+
+type sequence struct {
+	pairs []Pair
+	next  [2]int
+}
+
+func (s *sequence) Next(p Player) Pair {
+	pair := s.pairs[s.next[p]%len(s.pairs)]
+	s.next[p]++
+	return pair
+}
+
+func newTestMatch(pairs ...Pair) *Match {
+	return NewMatch(&sequence{pairs: pairs})
+}
+
+func mustApply(t *testing.T, m *Match, p Player, actions ...Action) Result {
+	t.Helper()
+	var res Result
+	for _, a := range actions {
+		var err error
+		res, err = m.Apply(p, a)
+		if err != nil {
+			t.Fatalf("Apply(%v, %v) error: %v", p, a, err)
+		}
+	}
+	return res
+}
+
+func TestNewMatch(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green})
+
+	if m.Turn() != Player1 || m.TurnCount() != 1 {
+		t.Errorf("expected turn 1 for p1, got %d for %v", m.TurnCount(), m.Turn())
+	}
+	if m.MovesLeft(Player1) != TurnMoves || m.MovesLeft(Player2) != 0 {
+		t.Errorf("unexpected moves: p1=%d p2=%d", m.MovesLeft(Player1), m.MovesLeft(Player2))
+	}
+	piece := m.Piece(Player1)
+	if piece == nil || piece.Row != SpawnRow || piece.Col != SpawnCol || piece.Rot != RotUp {
+		t.Errorf("unexpected spawn: %+v", piece)
+	}
+	if m.Piece(Player2) != nil {
+		t.Error("expected no piece for p2")
+	}
+}
+
+func TestApplyRejectsOutOfTurn(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green})
+
+	if _, err := m.Apply(Player2, ActionLeft); err != ErrNotYourTurn {
+		t.Errorf("expected ErrNotYourTurn, got %v", err)
+	}
+	if _, err := m.Apply(Player(5), ActionLeft); err != ErrUnknownPlayer {
+		t.Errorf("expected ErrUnknownPlayer, got %v", err)
+	}
+	if _, err := m.Apply(Player1, Action(99)); err != ErrUnknownAction {
+		t.Errorf("expected ErrUnknownAction, got %v", err)
+	}
+}
+
+func TestCheckLeavesMatchAlone(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green})
+	before := *m.Piece(Player1)
+
+	if err := m.Check(Player1, ActionHardDrop); err != nil {
+		t.Errorf("expected a hard drop to be allowed, got %v", err)
+	}
+	if err := m.Check(Player2, ActionLeft); err != ErrNotYourTurn {
+		t.Errorf("expected ErrNotYourTurn, got %v", err)
+	}
+	if err := m.Check(Player1, Action(99)); err != ErrUnknownAction {
+		t.Errorf("expected ErrUnknownAction, got %v", err)
+	}
+	if *m.Piece(Player1) != before || m.MovesLeft(Player1) != TurnMoves {
+		t.Error("expected Check not to change the match")
+	}
+}
+
+func TestForfeit(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green})
+
+	if err := m.Forfeit(Player1); err != nil {
+		t.Fatalf("Forfeit error: %v", err)
+	}
+	if !m.Over() || m.Winner() != Player2 {
+		t.Errorf("expected p2 to win, got over=%v winner=%v", m.Over(), m.Winner())
+	}
+	if err := m.Forfeit(Player2); err != ErrGameOver {
+		t.Errorf("expected ErrGameOver, got %v", err)
+	}
+	if _, err := m.Apply(Player1, ActionLeft); err != ErrGameOver {
+		t.Errorf("expected ErrGameOver, got %v", err)
+	}
+}
+
+func TestTurnSwitchesAfterBudget(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+
+	res := mustApply(t, m, Player1, ActionHardDrop)
+	if !res.Locked || res.TurnChanged {
+		t.Fatalf("expected lock without turn change, got %+v", res)
+	}
+	if m.MovesLeft(Player1) != 1 {
+		t.Errorf("expected 1 move left, got %d", m.MovesLeft(Player1))
+	}
+
+	res = mustApply(t, m, Player1, ActionLeft, ActionHardDrop)
+	if !res.TurnChanged {
+		t.Fatalf("expected turn change, got %+v", res)
+	}
+	if m.Turn() != Player2 || m.TurnCount() != 2 {
+		t.Errorf("expected turn 2 for p2, got %d for %v", m.TurnCount(), m.Turn())
+	}
+	if m.MovesLeft(Player1) != 0 || m.MovesLeft(Player2) != TurnMoves {
+		t.Errorf("unexpected moves: p1=%d p2=%d", m.MovesLeft(Player1), m.MovesLeft(Player2))
+	}
+	if m.Piece(Player1) != nil || m.Piece(Player2) == nil {
+		t.Error("expected the active piece to move to p2")
+	}
+	if _, err := m.Apply(Player1, ActionLeft); err != ErrNotYourTurn {
+		t.Errorf("expected ErrNotYourTurn, got %v", err)
+	}
+}
+
+func TestChainEndsTurnWithBonusMoves(t *testing.T) {
+	m := newTestMatch(Pair{Red, Red}, Pair{Blue, Yellow})
+	m.boards[Player1] = mustParse(t,
+		"R.....",
+		"R.....",
+	)
+
+	res := mustApply(t, m, Player1, ActionLeft, ActionLeft, ActionHardDrop)
+	if len(res.Chain) != 1 || !res.TurnChanged {
+		t.Fatalf("expected a 1-chain ending the turn, got %+v", res)
+	}
+	if m.Score(Player1) != 40 || m.MaxChain(Player1) != 1 {
+		t.Errorf("expected score 40 and max chain 1, got %d and %d", m.Score(Player1), m.MaxChain(Player1))
+	}
+	if m.MovesLeft(Player1) != 1 {
+		t.Errorf("expected p1 to keep 1 move, got %d", m.MovesLeft(Player1))
+	}
+	if want := ChainMoves + TurnMoves; m.MovesLeft(Player2) != want {
+		t.Errorf("expected p2 to have %d moves, got %d", want, m.MovesLeft(Player2))
+	}
+}
+
+func TestMovementAndKicks(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green})
+
+	mustApply(t, m, Player1, ActionLeft, ActionLeft, ActionLeft)
+	if p := m.Piece(Player1); p.Col != 0 {
+		t.Fatalf("expected column 0, got %d", p.Col)
+	}
+
+	// Rotating towards the wall kicks the pair right.
+	mustApply(t, m, Player1, ActionRotateCCW)
+	p := m.Piece(Player1)
+	if p.Rot != RotLeft || p.Col != 1 {
+		t.Errorf("expected kicked left rotation at column 1, got %+v", p)
+	}
+
+	mustApply(t, m, Player1, ActionDrop)
+	if got := m.Piece(Player1).Row; got != SpawnRow+1 {
+		t.Errorf("expected soft drop to row %d, got %d", SpawnRow+1, got)
+	}
+
+	for i := 0; i < Rows; i++ {
+		mustApply(t, m, Player1, ActionDrop)
+	}
+	board := m.Board(Player1)
+	if board.At(Rows-1, 0) != Green || board.At(Rows-1, 1) != Red {
+		t.Errorf("unexpected board after lock:\n%s", board)
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green})
+	rows := make([]string, Rows-1)
+	for i := range rows {
+		rows[i] = "######"
+	}
+	m.boards[Player1] = mustParse(t, rows...)
+
+	res := mustApply(t, m, Player1, ActionHardDrop)
+	if !res.GameOver || !m.Over() {
+		t.Fatalf("expected game over, got %+v", res)
+	}
+	if m.Winner() != Player2 {
+		t.Errorf("expected p2 to win, got %v", m.Winner())
+	}
+	if _, err := m.Apply(Player1, ActionLeft); err != ErrGameOver {
+		t.Errorf("expected ErrGameOver, got %v", err)
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for a := ActionLeft; a <= ActionHardDrop; a++ {
+		got, err := ParseAction(a.String())
+		if err != nil || got != a {
+			t.Errorf("ParseAction(%q) = %v, %v", a.String(), got, err)
+		}
+	}
+	if _, err := ParseAction("teleport"); err != ErrUnknownAction {
+		t.Errorf("expected ErrUnknownAction, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package game
+
+// Nuisance rules. Points convert at a fixed rate with the remainder carried
+// over, new nuisance first cancels the attacker's own pending nuisance, and
+// whatever is still pending at the end of a player's turn falls on them.
+const (
+	// NuisanceRate is the number of points that make one nuisance puyo.
+	NuisanceRate = 70
+	// MaxNuisanceDrop is the most nuisance that falls at once (five full
+	// rows). The rest stays pending for the next turn.
+	MaxNuisanceDrop = 30
+)
+
+// NuisanceResult reports how nuisance was settled at the end of a turn.
+type NuisanceResult struct {
+	Generated int
+	Offset    int
+	Sent      int
+	Dropped   int
+}
+
+// ConvertScore turns points into nuisance. remainder is the carry from
+// previous conversions and the new carry is returned.
+func ConvertScore(points, remainder int) (nuisance, carry int) {
+	total := points + remainder
+	return total / NuisanceRate, total % NuisanceRate
+}
+
+// Offset cancels generated nuisance against the attacker's pending
+// nuisance. It returns what is left pending and the excess sent on to the
+// opponent.
+func Offset(generated, pending int) (remaining, sent int) {
+	if generated >= pending {
+		return 0, generated - pending
+	}
+	return pending - generated, 0
+}
+
+// DropNuisance drops rows full rows of nuisance, then one nuisance puyo in
+// each of columns. Puyos that land above the top row are lost.
+func (b *Board) DropNuisance(rows int, columns []int) {
+	for range rows {
+		for c := 0; c < Cols; c++ {
+			b.drop(c, Garbage)
+		}
+	}
+	for _, c := range columns {
+		if c >= 0 && c < Cols {
+			b.drop(c, Garbage)
+		}
+	}
+}
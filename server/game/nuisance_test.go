@@ -0,0 +1,155 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestConvertScore(t *testing.T) {
+	tests := []struct {
+		points, remainder int
+		nuisance, carry   int
+	}{
+		{0, 0, 0, 0},
+		{69, 0, 0, 69},
+		{70, 0, 1, 0},
+		{40, 40, 1, 10},
+		{360, 10, 5, 20},
+	}
+	for _, tt := range tests {
+		n, carry := ConvertScore(tt.points, tt.remainder)
+		if n != tt.nuisance || carry != tt.carry {
+			t.Errorf("ConvertScore(%d, %d): expected %d carry %d, got %d carry %d",
+				tt.points, tt.remainder, tt.nuisance, tt.carry, n, carry)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		generated, pending int
+		remaining, sent    int
+	}{
+		{5, 0, 0, 5},
+		{5, 3, 0, 2},
+		{3, 5, 2, 0},
+		{4, 4, 0, 0},
+	}
+	for _, tt := range tests {
+		remaining, sent := Offset(tt.generated, tt.pending)
+		if remaining != tt.remaining || sent != tt.sent {
+			t.Errorf("Offset(%d, %d): expected %d/%d, got %d/%d",
+				tt.generated, tt.pending, tt.remaining, tt.sent, remaining, sent)
+		}
+	}
+}
+
+func TestDropNuisance(t *testing.T) {
+	b := mustParse(t,
+		"R.....",
+		"R.G...",
+	)
+	b.DropNuisance(1, []int{0, 5})
+
+	want := mustParse(t,
+		"#.....",
+		"#.....",
+		"R.#..#",
+		"R#G###",
+	)
+	if b.String() != want.String() {
+		t.Errorf("expected\n%s\ngot\n%s", want, b)
+	}
+
+	t.Run("Overflow", func(t *testing.T) {
+		b := NewBoard()
+		b.DropNuisance(Rows+1, nil)
+		if b.Height(0) != Rows {
+			t.Errorf("expected a full column, got height %d", b.Height(0))
+		}
+	})
+}
+
+func TestMatchDropsPendingNuisance(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+	m.pending[Player1] = Cols + 2
+
+	mustApply(t, m, Player1, ActionHardDrop)
+	res := mustApply(t, m, Player1, ActionHardDrop)
+	if !res.TurnChanged || res.Nuisance == nil {
+		t.Fatalf("expected the turn to end with nuisance, got %+v", res)
+	}
+	if res.Nuisance.Dropped != Cols+2 || m.Pending(Player1) != 0 {
+		t.Errorf("expected %d dropped and none pending, got %+v pending %d", Cols+2, res.Nuisance, m.Pending(Player1))
+	}
+	// One full row, then loose nuisance from the left.
+	board := m.Board(Player1)
+	for c, want := range []int{2, 2, 5, 1, 1, 1} {
+		if board.Height(c) != want {
+			t.Errorf("column %d: expected height %d, got %d\n%s", c, want, board.Height(c), board)
+		}
+	}
+}
+
+func TestMatchCapsNuisanceDrop(t *testing.T) {
+	m := newTestMatch(Pair{Red, Green}, Pair{Blue, Yellow})
+	m.pending[Player1] = MaxNuisanceDrop + 4
+
+	mustApply(t, m, Player1, ActionHardDrop, ActionHardDrop)
+	if m.Pending(Player1) != 4 {
+		t.Errorf("expected 4 nuisance to stay pending, got %d", m.Pending(Player1))
+	}
+}
+
+func TestMatchSendsAndOffsetsNuisance(t *testing.T) {
+	setup := func(pending int) *Match {
+		m := newTestMatch(Pair{Red, Red}, Pair{Blue, Yellow})
+		m.boards[Player1] = mustParse(t,
+			"R.....",
+			"R.....",
+		)
+		// The 40 points of the chain below plus this carry make exactly
+		// one nuisance.
+		m.remainder[Player1] = 30
+		m.pending[Player1] = pending
+		return m
+	}
+
+	t.Run("Send", func(t *testing.T) {
+		m := setup(0)
+		res := mustApply(t, m, Player1, ActionLeft, ActionLeft, ActionHardDrop)
+		want := NuisanceResult{Generated: 1, Sent: 1}
+		if res.Nuisance == nil || *res.Nuisance != want {
+			t.Fatalf("expected %+v, got %+v", want, res.Nuisance)
+		}
+		if m.Pending(Player2) != 1 || m.remainder[Player1] != 0 {
+			t.Errorf("expected p2 pending 1 and no carry, got %d and %d", m.Pending(Player2), m.remainder[Player1])
+		}
+	})
+
+	t.Run("Offset", func(t *testing.T) {
+		m := setup(3)
+		res := mustApply(t, m, Player1, ActionLeft, ActionLeft, ActionHardDrop)
+		want := NuisanceResult{Generated: 1, Offset: 1, Dropped: 2}
+		if res.Nuisance == nil || *res.Nuisance != want {
+			t.Fatalf("expected %+v, got %+v", want, res.Nuisance)
+		}
+		if m.Pending(Player1) != 0 || m.Pending(Player2) != 0 {
+			t.Errorf("expected nothing pending, got %d and %d", m.Pending(Player1), m.Pending(Player2))
+		}
+	})
+}
+
+func TestGeneratorPickColumns(t *testing.T) {
+	a := NewGenerator(11, true).PickColumns(4)
+	b := NewGenerator(11, true).PickColumns(4)
+	seen := map[int]bool{}
+	for i, c := range a {
+		if c != b[i] {
+			t.Fatalf("expected the same columns for the same seed, got %v and %v", a, b)
+		}
+		if c < 0 || c >= Cols || seen[c] {
+			t.Fatalf("expected distinct columns in range, got %v", a)
+		}
+		seen[c] = true
+	}
+}
@@ -0,0 +1,43 @@
+package game
+
+import "fmt"
+
+// Input is one action a match accepted, as recorded for replays. Turn is
+// the turn count at the time and guards against replaying out of order.
+type Input struct {
+	Player Player
+	Turn   int
+	Action Action
+}
+
+// Frame is the state of a replayed match right after one input.
+type Frame struct {
+	Input  Input
+	Boards [2]*Board
+	Pieces [2]*Piece
+	Result Result
+}
+
+// Replay plays inputs on a fresh match built from seed and returns the
+// frame after each of them along with the final match. Nuisance columns
+// come from the same generator, so every board matches the original game.
+func Replay(seed uint64, shared bool, inputs []Input) (*Match, []Frame, error) {
+	m := NewMatch(NewGenerator(seed, shared))
+	frames := make([]Frame, 0, len(inputs))
+	for i, in := range inputs {
+		if m.TurnCount() != in.Turn {
+			return m, frames, fmt.Errorf("input %d: recorded on turn %d, replaying turn %d", i, in.Turn, m.TurnCount())
+		}
+		res, err := m.Apply(in.Player, in.Action)
+		if err != nil {
+			return m, frames, fmt.Errorf("input %d: %w", i, err)
+		}
+		frames = append(frames, Frame{
+			Input:  in,
+			Boards: [2]*Board{m.Board(Player1), m.Board(Player2)},
+			Pieces: [2]*Piece{m.Piece(Player1), m.Piece(Player2)},
+			Result: res,
+		})
+	}
+	return m, frames, nil
+}
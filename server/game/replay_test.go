@@ -0,0 +1,78 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+// playRecorded plays a match with a seeded generator, hard dropping every
+// piece in a rotating column, and records the inputs it accepted.
+func playRecorded(t *testing.T, seed uint64, shared bool, turns int) (*Match, []Input) {
+	t.Helper()
+	m := NewMatch(NewGenerator(seed, shared))
+	var inputs []Input
+	apply := func(a Action) {
+		in := Input{Player: m.Turn(), Turn: m.TurnCount(), Action: a}
+		if _, err := m.Apply(in.Player, a); err != nil {
+			t.Fatalf("Apply(%v, %v) error: %v", in.Player, a, err)
+		}
+		inputs = append(inputs, in)
+	}
+	for i := 0; i < turns && !m.Over(); i++ {
+		switch i % 3 {
+		case 0:
+			apply(ActionLeft)
+		case 1:
+			apply(ActionRotateCW)
+		}
+		apply(ActionHardDrop)
+	}
+	return m, inputs
+}
+
+func TestReplay(t *testing.T) {
+	original, inputs := playRecorded(t, 42, false, 60)
+
+	replayed, frames, err := Replay(42, false, inputs)
+	if err != nil {
+		t.Fatalf("Replay error: %v", err)
+	}
+	if len(frames) != len(inputs) {
+		t.Fatalf("expected %d frames, got %d", len(inputs), len(frames))
+	}
+	for p := range 2 {
+		player := Player(p)
+		if got, want := replayed.Board(player).String(), original.Board(player).String(); got != want {
+			t.Errorf("%v board differs:\n%s\nexpected:\n%s", player, got, want)
+		}
+		if replayed.Score(player) != original.Score(player) {
+			t.Errorf("%v: expected score %d, got %d", player, original.Score(player), replayed.Score(player))
+		}
+	}
+	last := frames[len(frames)-1]
+	if got, want := last.Boards[Player1].String(), original.Board(Player1).String(); got != want {
+		t.Errorf("last frame differs from final board:\n%s\nexpected:\n%s", got, want)
+	}
+
+	t.Run("FramesAreSnapshots", func(t *testing.T) {
+		if frames[0].Boards[Player1].String() == last.Boards[Player1].String() {
+			t.Error("expected the first frame to keep its own board")
+		}
+	})
+
+	t.Run("WrongTurn", func(t *testing.T) {
+		bad := append([]Input(nil), inputs...)
+		bad[0].Turn = 2
+		_, frames, err := Replay(42, false, bad)
+		if err == nil || len(frames) != 0 {
+			t.Errorf("expected an error before any frame, got %v with %d frames", err, len(frames))
+		}
+	})
+
+	t.Run("RejectedInput", func(t *testing.T) {
+		bad := []Input{{Player: Player2, Turn: 1, Action: ActionLeft}}
+		if _, _, err := Replay(42, false, bad); !errors.Is(err, ErrNotYourTurn) {
+			t.Errorf("expected ErrNotYourTurn, got %v", err)
+		}
+	})
+}
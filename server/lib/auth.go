@@ -18,3 +18,15 @@ func GetUserContext(ctx context.Context) (db.User, error) {
 	}
 	return user, nil
 }
+
+func SetSessionContext(ctx context.Context, session db.Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+func GetSessionContext(ctx context.Context) (db.Session, error) {
+	session, ok := ctx.Value(sessionContextKey).(db.Session)
+	if !ok {
+		return db.Session{}, errors.New("session not found")
+	}
+	return session, nil
+}
@@ -12,9 +12,12 @@ import (
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey    contextKey = "user"
+	sessionContextKey contextKey = "session"
+)
 
-func AuthMiddleware(queries *db.Queries) func(http.Handler) http.Handler {
+func AuthMiddleware(queries *db.Queries, policy SessionPolicy) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie("session_id")
@@ -35,12 +38,18 @@ func AuthMiddleware(queries *db.Queries) func(http.Handler) http.Handler {
 				return
 			}
 
-			if time.Now().After(session.ExpiresAt) {
+			now := time.Now()
+			if now.After(session.ExpiresAt) {
 				// Session expired
 				next.ServeHTTP(w, r)
 				return
 			}
 
+			session, _, err = RenewSession(r.Context(), queries, policy, session, now)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "RenewSession error", "error", err)
+			}
+
 			user, err := queries.GetUser(r.Context(), session.UserID)
 			if err != nil {
 				slog.ErrorContext(r.Context(), "GetUser error", "error", err)
@@ -48,8 +57,9 @@ func AuthMiddleware(queries *db.Queries) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Add user to context
+			// Add user and session to context
 			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, sessionContextKey, session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -59,8 +69,7 @@ func RequireAuthMiddleware(next HandlerFunc) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		_, ok := r.Context().Value(userContextKey).(db.User)
 		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return nil
+			return NewError(http.StatusUnauthorized, CodeUnauthorized, "Sign in first")
 		}
 		return next(w, r)
 	}
@@ -20,9 +20,7 @@ func TestRequireAuthMiddleware(t *testing.T) {
 			return nil
 		})
 
-		if err := RequireAuthMiddleware(next)(w, req); err != nil {
-			t.Errorf("expected no error, got %v", err)
-		}
+		RequireAuthMiddleware(next).ServeHTTP(w, req)
 
 		if w.Code != http.StatusUnauthorized {
 			t.Errorf("expected status 401, got %d", w.Code)
@@ -43,9 +41,7 @@ func TestRequireAuthMiddleware(t *testing.T) {
 			return nil
 		})
 
-		if err := RequireAuthMiddleware(next)(w, req); err != nil {
-			t.Errorf("expected no error, got %v", err)
-		}
+		RequireAuthMiddleware(next).ServeHTTP(w, req)
 
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status 200, got %d", w.Code)
@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/sodefrin/PP/server/db"
+)
+
+// BotUser returns the user bots play as, creating it on first use. Bots
+// have no password and cannot sign in. If someone already signed up as
+// "bot", the bot user gets a numbered name instead.
+func BotUser(ctx context.Context, queries *db.Queries) (db.User, error) {
+	user, err := queries.GetBotUser(ctx)
+	if err != sql.ErrNoRows {
+		return user, err
+	}
+
+	name := "bot"
+	for n := 2; ; n++ {
+		_, err := queries.GetUserByName(ctx, name)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return db.User{}, err
+		}
+		name = fmt.Sprintf("bot-%d", n)
+	}
+	return queries.CreateBotUser(ctx, name)
+}
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sodefrin/PP/server/db"
+)
+
+func TestBotUser(t *testing.T) {
+	_, queries := newTestDB(t)
+	ctx := context.Background()
+
+	// A human got the plain name first.
+	if _, err := queries.CreateUser(ctx, db.CreateUserParams{Name: "bot", PasswordHash: "x"}); err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+
+	bot, err := BotUser(ctx, queries)
+	if err != nil {
+		t.Fatalf("BotUser error: %v", err)
+	}
+	if !bot.Bot || bot.Name != "bot-2" || bot.PasswordHash != "" {
+		t.Errorf("unexpected bot user %+v", bot)
+	}
+
+	again, err := BotUser(ctx, queries)
+	if err != nil {
+		t.Fatalf("BotUser error: %v", err)
+	}
+	if again.ID != bot.ID {
+		t.Errorf("expected the bot user to be reused, got %d and %d", bot.ID, again.ID)
+	}
+}
@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sodefrin/PP/server/game"
+)
+
+// Config holds the settings read from the environment at startup.
+type Config struct {
+	// DBPath is the SQLite database file.
+	DBPath string
+	// DevMode relaxes checks that get in the way of local development,
+	// such as the websocket origin allowlist.
+	DevMode bool
+	// AllowedOrigins lists the origins, besides the server's own, that may
+	// open a websocket.
+	AllowedOrigins []string
+	// Session controls session lifetimes and sliding renewal.
+	Session SessionPolicy
+	// Password is the policy new passwords must meet. Breached passwords
+	// are read from the file at PASSWORD_BREACHED_LIST, if set.
+	Password PasswordPolicy
+	// SessionSweepInterval is how often expired sessions are deleted.
+	SessionSweepInterval time.Duration
+	// SpectatorDelay is how far behind the players spectators watch.
+	SpectatorDelay time.Duration
+	// ReconnectGrace is how long a player who lost their connection
+	// mid-match has to come back before forfeiting.
+	ReconnectGrace time.Duration
+	// TimeLimits bound turns and moves. They are off unless
+	// WS_TURN_TIME_LIMIT or WS_MOVE_TIME_LIMIT is set.
+	TimeLimits game.TimeLimits
+}
+
+func LoadConfig() (Config, error) {
+	var cfg Config
+	var err error
+
+	cfg.DBPath = envString("DB_PATH", "pp.db")
+	if cfg.DevMode, err = envBool("DEV_MODE", false); err != nil {
+		return Config{}, err
+	}
+	cfg.AllowedOrigins = envList("WS_ALLOWED_ORIGINS")
+
+	cfg.Session = DefaultSessionPolicy()
+	if cfg.Session.AbsoluteTimeout, err = envDuration("SESSION_ABSOLUTE_TIMEOUT", cfg.Session.AbsoluteTimeout); err != nil {
+		return Config{}, err
+	}
+	if cfg.Session.IdleTimeout, err = envDuration("SESSION_IDLE_TIMEOUT", cfg.Session.IdleTimeout); err != nil {
+		return Config{}, err
+	}
+	if cfg.Session.Sliding, err = envBool("SESSION_SLIDING", false); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Session.Validate(); err != nil {
+		return Config{}, err
+	}
+	cfg.Password = DefaultPasswordPolicy()
+	if cfg.Password.MinLength, err = envInt("PASSWORD_MIN_LENGTH", cfg.Password.MinLength); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Password.Validate(); err != nil {
+		return Config{}, err
+	}
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		if cfg.Password.Breached, err = LoadBreachedPasswords(path); err != nil {
+			return Config{}, fmt.Errorf("PASSWORD_BREACHED_LIST: %w", err)
+		}
+	}
+	if cfg.SessionSweepInterval, err = envDuration("SESSION_SWEEP_INTERVAL", 10*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.SessionSweepInterval <= 0 {
+		return Config{}, errors.New("SESSION_SWEEP_INTERVAL must be positive")
+	}
+	if cfg.SpectatorDelay, err = envDuration("WS_SPECTATOR_DELAY", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.SpectatorDelay < 0 {
+		return Config{}, errors.New("WS_SPECTATOR_DELAY must not be negative")
+	}
+	if cfg.ReconnectGrace, err = envDuration("WS_RECONNECT_GRACE", 30*time.Second); err != nil {
+		return Config{}, err
+	}
+	if cfg.ReconnectGrace < 0 {
+		return Config{}, errors.New("WS_RECONNECT_GRACE must not be negative")
+	}
+	if cfg.TimeLimits.Turn, err = envDuration("WS_TURN_TIME_LIMIT", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.TimeLimits.Move, err = envDuration("WS_MOVE_TIME_LIMIT", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.TimeLimits.MaxTimeouts, err = envInt("WS_MAX_TIMEOUTS", 3); err != nil {
+		return Config{}, err
+	}
+	if cfg.TimeLimits.Turn < 0 || cfg.TimeLimits.Move < 0 || cfg.TimeLimits.MaxTimeouts < 0 {
+		return Config{}, errors.New("WS_TURN_TIME_LIMIT, WS_MOVE_TIME_LIMIT and WS_MAX_TIMEOUTS must not be negative")
+	}
+
+	return cfg, nil
+}
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+	return b, nil
+}
+
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return n, nil
+}
+
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return d, nil
+}
+
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,166 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/game"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		t.Setenv("DEV_MODE", "")
+		t.Setenv("WS_ALLOWED_ORIGINS", "")
+		t.Setenv("DB_PATH", "")
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		if cfg.DevMode || len(cfg.AllowedOrigins) != 0 || cfg.DBPath != "pp.db" {
+			t.Errorf("unexpected defaults: %+v", cfg)
+		}
+	})
+
+	t.Run("FromEnv", func(t *testing.T) {
+		t.Setenv("DEV_MODE", "true")
+		t.Setenv("WS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com,")
+		t.Setenv("DB_PATH", "/var/lib/pp/pp.db")
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		if cfg.DBPath != "/var/lib/pp/pp.db" {
+			t.Errorf("expected DB path from env, got %q", cfg.DBPath)
+		}
+		if !cfg.DevMode {
+			t.Error("expected dev mode")
+		}
+		want := []string{"https://a.example.com", "https://b.example.com"}
+		if !reflect.DeepEqual(cfg.AllowedOrigins, want) {
+			t.Errorf("expected %v, got %v", want, cfg.AllowedOrigins)
+		}
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		t.Setenv("SESSION_ABSOLUTE_TIMEOUT", "12h")
+		t.Setenv("SESSION_IDLE_TIMEOUT", "45m")
+		t.Setenv("SESSION_SLIDING", "true")
+		t.Setenv("SESSION_SWEEP_INTERVAL", "1m")
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		want := SessionPolicy{AbsoluteTimeout: 12 * time.Hour, IdleTimeout: 45 * time.Minute, Sliding: true}
+		if cfg.Session != want {
+			t.Errorf("expected %+v, got %+v", want, cfg.Session)
+		}
+		if cfg.SessionSweepInterval != time.Minute {
+			t.Errorf("expected sweep interval 1m, got %v", cfg.SessionSweepInterval)
+		}
+	})
+
+	t.Run("SpectatorDelay", func(t *testing.T) {
+		t.Setenv("WS_SPECTATOR_DELAY", "30s")
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		if cfg.SpectatorDelay != 30*time.Second {
+			t.Errorf("expected spectator delay 30s, got %v", cfg.SpectatorDelay)
+		}
+
+		t.Setenv("WS_SPECTATOR_DELAY", "-1s")
+		if _, err := LoadConfig(); err == nil {
+			t.Error("expected error for negative WS_SPECTATOR_DELAY")
+		}
+	})
+
+	t.Run("ReconnectGrace", func(t *testing.T) {
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		if cfg.ReconnectGrace != 30*time.Second {
+			t.Errorf("expected default grace 30s, got %v", cfg.ReconnectGrace)
+		}
+
+		t.Setenv("WS_RECONNECT_GRACE", "0s")
+		if cfg, err = LoadConfig(); err != nil || cfg.ReconnectGrace != 0 {
+			t.Errorf("expected grace 0, got %v (%v)", cfg.ReconnectGrace, err)
+		}
+	})
+
+	t.Run("TimeLimits", func(t *testing.T) {
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		if cfg.TimeLimits.Enabled() || cfg.TimeLimits.MaxTimeouts != 3 {
+			t.Errorf("expected no time limits by default, got %+v", cfg.TimeLimits)
+		}
+
+		t.Setenv("WS_TURN_TIME_LIMIT", "30s")
+		t.Setenv("WS_MOVE_TIME_LIMIT", "10s")
+		t.Setenv("WS_MAX_TIMEOUTS", "2")
+		if cfg, err = LoadConfig(); err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		want := game.TimeLimits{Turn: 30 * time.Second, Move: 10 * time.Second, MaxTimeouts: 2}
+		if cfg.TimeLimits != want {
+			t.Errorf("expected %+v, got %+v", want, cfg.TimeLimits)
+		}
+
+		t.Setenv("WS_MAX_TIMEOUTS", "many")
+		if _, err := LoadConfig(); err == nil {
+			t.Error("expected error for invalid WS_MAX_TIMEOUTS")
+		}
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "breached.txt")
+		if err := os.WriteFile(path, []byte("hunter22\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PASSWORD_MIN_LENGTH", "12")
+		t.Setenv("PASSWORD_BREACHED_LIST", path)
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		if _, ok := cfg.Password.Breached["hunter22"]; cfg.Password.MinLength != 12 || !ok {
+			t.Errorf("unexpected password policy %+v", cfg.Password)
+		}
+
+		t.Setenv("PASSWORD_BREACHED_LIST", path+".missing")
+		if _, err := LoadConfig(); err == nil {
+			t.Error("expected error for a missing PASSWORD_BREACHED_LIST")
+		}
+		t.Setenv("PASSWORD_BREACHED_LIST", "")
+		t.Setenv("PASSWORD_MIN_LENGTH", "0")
+		if _, err := LoadConfig(); err == nil {
+			t.Error("expected error for PASSWORD_MIN_LENGTH 0")
+		}
+	})
+
+	t.Run("InvalidDuration", func(t *testing.T) {
+		t.Setenv("SESSION_IDLE_TIMEOUT", "soon")
+		if _, err := LoadConfig(); err == nil {
+			t.Error("expected error for invalid SESSION_IDLE_TIMEOUT")
+		}
+	})
+
+	t.Run("InvalidBool", func(t *testing.T) {
+		t.Setenv("DEV_MODE", "sometimes")
+		if _, err := LoadConfig(); err == nil {
+			t.Error("expected error for invalid DEV_MODE")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Error codes sent in ErrorBody. Clients branch on the code; the message
+// is for people.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeBodyTooLarge       = "body_too_large"
+	CodeNameTaken          = "name_taken"
+	CodeAlreadyInRoom      = "already_in_room"
+	CodeAlreadyQueued      = "already_queued"
+	CodeRoomNotOpen        = "room_not_open"
+	CodeInternal           = "internal"
+)
+
+// Error is a failure to report to the client. A HandlerFunc returns it
+// instead of writing a response; any other error becomes a 500.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+// FieldError points at one invalid field of the request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func NewError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return NewError(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+func NotFound(message string) *Error {
+	return NewError(http.StatusNotFound, CodeNotFound, message)
+}
+
+// WithField returns a copy of e that also reports field as invalid.
+func (e *Error) WithField(field, message string) *Error {
+	out := *e
+	out.Fields = append(append([]FieldError(nil), e.Fields...), FieldError{Field: field, Message: message})
+	return &out
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// ErrorResponse is the body of every API error response.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody carries the trace id of the request, when it is traced, so a
+// report from a user can be found in the traces.
+type ErrorBody struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	TraceID string       `json:"trace_id,omitempty"`
+}
+
+// WriteError renders err as JSON. Errors other than *Error are logged and
+// reported as an internal error without details.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		slog.ErrorContext(r.Context(), "internal server error", "error", err)
+		apiErr = NewError(http.StatusInternalServerError, CodeInternal, "Internal server error")
+	}
+
+	resp := ErrorResponse{Error: ErrorBody{
+		Code:    apiErr.Code,
+		Message: apiErr.Message,
+		Fields:  apiErr.Fields,
+	}}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		resp.Error.TraceID = sc.TraceID().String()
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := WriteJSON(w, apiErr.Status, resp); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write error response", "error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHandlerFuncErrors(t *testing.T) {
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) ErrorBody {
+		t.Helper()
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("expected a JSON error, got %q", ct)
+		}
+		var resp ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode error: %v", err)
+		}
+		return resp.Error
+	}
+
+	t.Run("APIError", func(t *testing.T) {
+		handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return NewError(http.StatusConflict, CodeNameTaken, "Name is already taken").WithField("name", "taken")
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected status 409, got %d", w.Code)
+		}
+		body := decode(t, w)
+		if body.Code != CodeNameTaken || body.Message != "Name is already taken" {
+			t.Errorf("unexpected body %+v", body)
+		}
+		if len(body.Fields) != 1 || body.Fields[0] != (FieldError{Field: "name", Message: "taken"}) {
+			t.Errorf("unexpected fields %+v", body.Fields)
+		}
+	})
+
+	t.Run("Wrapped", func(t *testing.T) {
+		handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.Join(errors.New("context"), NotFound("Room not found"))
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusNotFound || decode(t, w).Code != CodeNotFound {
+			t.Errorf("expected not_found, got %d", w.Code)
+		}
+	})
+
+	t.Run("Internal", func(t *testing.T) {
+		handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New("database is on fire")
+		})
+		traceID := trace.TraceID{1, 2, 3}
+		ctx := trace.ContextWithSpanContext(t.Context(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  trace.SpanID{1},
+		}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+		body := decode(t, w)
+		if body.Code != CodeInternal || body.Message != "Internal server error" {
+			t.Errorf("expected the cause to be hidden, got %+v", body)
+		}
+		if body.TraceID != traceID.String() {
+			t.Errorf("expected trace id %s, got %q", traceID, body.TraceID)
+		}
+	})
+}
+
+func TestErrorWithFieldCopies(t *testing.T) {
+	base := BadRequest("Invalid request body")
+	withName := base.WithField("name", "required")
+	withBoth := withName.WithField("password", "required")
+
+	if len(base.Fields) != 0 || len(withName.Fields) != 1 || len(withBoth.Fields) != 2 {
+		t.Errorf("expected WithField to leave its receiver alone, got %d, %d, %d fields",
+			len(base.Fields), len(withName.Fields), len(withBoth.Fields))
+	}
+}
@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/rating"
+)
+
+// Reasons a match ended, as stored in matches.end_reason.
+const (
+	MatchEndTopOut  = "topout"
+	MatchEndForfeit = "forfeit"
+	MatchEndTimeout = "timeout"
+)
+
+// RecordMatch stores a finished match, marks its room finished and updates
+// the ratings of both players in a single transaction. Matches against a
+// bot are not rated.
+func RecordMatch(ctx context.Context, conn *sql.DB, queries *db.Queries, params db.CreateMatchParams) (db.Match, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Match{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := queries.WithTx(tx)
+
+	match, err := qtx.CreateMatch(ctx, params)
+	if err != nil {
+		return db.Match{}, err
+	}
+
+	room, err := qtx.GetRoom(ctx, match.RoomID)
+	if err != nil {
+		return db.Match{}, err
+	}
+	if ValidateRoomTransition(room.Status, RoomStatusFinished) == nil {
+		if _, err := qtx.UpdateRoomStatus(ctx, db.UpdateRoomStatusParams{
+			Status: RoomStatusFinished,
+			ID:     room.ID,
+		}); err != nil {
+			return db.Match{}, err
+		}
+	}
+
+	if err := updateRatings(ctx, qtx, match); err != nil {
+		return db.Match{}, err
+	}
+	return match, tx.Commit()
+}
+
+func updateRatings(ctx context.Context, queries *db.Queries, match db.Match) error {
+	p1, err := queries.GetUser(ctx, match.P1ID)
+	if err != nil {
+		return err
+	}
+	p2, err := queries.GetUser(ctx, match.P2ID)
+	if err != nil {
+		return err
+	}
+	// Games against bots are practice and leave ratings alone.
+	if p1.Bot || p2.Bot {
+		return nil
+	}
+
+	outcome := rating.Draw
+	if match.WinnerID.Valid {
+		outcome = rating.Loss
+		if match.WinnerID.Int64 == p1.ID {
+			outcome = rating.Win
+		}
+	}
+	r1, r2 := rating.Update(p1.Rating, p2.Rating, outcome)
+
+	for _, u := range []struct {
+		user  db.User
+		after int64
+	}{{p1, r1}, {p2, r2}} {
+		if err := queries.UpdateUserRating(ctx, db.UpdateUserRatingParams{
+			Rating: u.after,
+			ID:     u.user.ID,
+		}); err != nil {
+			return err
+		}
+		if err := queries.CreateRatingHistory(ctx, db.CreateRatingHistoryParams{
+			UserID:       u.user.ID,
+			MatchID:      match.ID,
+			RatingBefore: u.user.Rating,
+			RatingAfter:  u.after,
+			CreatedAt:    match.EndedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
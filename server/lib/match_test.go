@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/rating"
+)
+
+func TestRecordMatch(t *testing.T) {
+	conn, queries := newTestDB(t)
+	ctx := context.Background()
+
+	// newTestDB creates user 1.
+	bob, err := queries.CreateUser(ctx, db.CreateUserParams{Name: "bob", PasswordHash: "x"})
+	if err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	newRoom := func() db.Room {
+		room, err := queries.CreateRoom(ctx, db.CreateRoomParams{
+			P1ID:   sql.NullInt64{Int64: 1, Valid: true},
+			Status: RoomStatusPlaying,
+		})
+		if err != nil {
+			t.Fatalf("CreateRoom error: %v", err)
+		}
+		return room
+	}
+	params := func(roomID int64) db.CreateMatchParams {
+		now := time.Now().UTC()
+		return db.CreateMatchParams{
+			RoomID:    roomID,
+			P1ID:      1,
+			P2ID:      bob.ID,
+			WinnerID:  sql.NullInt64{Int64: 1, Valid: true},
+			EndReason: MatchEndTopOut,
+			StartedAt: now.Add(-time.Minute),
+			EndedAt:   now,
+		}
+	}
+
+	t.Run("UpdatesRatings", func(t *testing.T) {
+		room := newRoom()
+		match, err := RecordMatch(ctx, conn, queries, params(room.ID))
+		if err != nil {
+			t.Fatalf("RecordMatch error: %v", err)
+		}
+
+		wantWinner, wantLoser := rating.Update(rating.Initial, rating.Initial, rating.Win)
+		for id, want := range map[int64]int64{1: wantWinner, bob.ID: wantLoser} {
+			user, err := queries.GetUser(ctx, id)
+			if err != nil {
+				t.Fatalf("GetUser error: %v", err)
+			}
+			if user.Rating != want {
+				t.Errorf("user %d: expected rating %d, got %d", id, want, user.Rating)
+			}
+		}
+
+		var history int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM rating_history WHERE match_id = ?", match.ID).Scan(&history); err != nil {
+			t.Fatalf("count rating_history: %v", err)
+		}
+		if history != 2 {
+			t.Errorf("expected 2 history rows, got %d", history)
+		}
+
+		room, err = queries.GetRoom(ctx, room.ID)
+		if err != nil {
+			t.Fatalf("GetRoom error: %v", err)
+		}
+		if room.Status != RoomStatusFinished {
+			t.Errorf("expected room to be finished, got %s", room.Status)
+		}
+	})
+
+	t.Run("BotUnrated", func(t *testing.T) {
+		bot, err := BotUser(ctx, queries)
+		if err != nil {
+			t.Fatalf("BotUser error: %v", err)
+		}
+		before, err := queries.GetUser(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUser error: %v", err)
+		}
+		p := params(newRoom().ID)
+		p.P2ID = bot.ID
+		match, err := RecordMatch(ctx, conn, queries, p)
+		if err != nil {
+			t.Fatalf("RecordMatch error: %v", err)
+		}
+
+		after, err := queries.GetUser(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUser error: %v", err)
+		}
+		if after.Rating != before.Rating {
+			t.Errorf("expected rating %d to be unchanged, got %d", before.Rating, after.Rating)
+		}
+		var history int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM rating_history WHERE match_id = ?", match.ID).Scan(&history); err != nil {
+			t.Fatalf("count rating_history: %v", err)
+		}
+		if history != 0 {
+			t.Errorf("expected no history rows, got %d", history)
+		}
+	})
+
+	t.Run("RollsBack", func(t *testing.T) {
+		room := newRoom()
+		p := params(room.ID)
+		p.P2ID = 999999
+		if _, err := RecordMatch(ctx, conn, queries, p); err == nil {
+			t.Fatal("expected error for an unknown player")
+		}
+
+		var matches int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM matches WHERE room_id = ?", room.ID).Scan(&matches); err != nil {
+			t.Fatalf("count matches: %v", err)
+		}
+		if matches != 0 {
+			t.Errorf("expected the match insert to be rolled back, got %d rows", matches)
+		}
+		room, err := queries.GetRoom(ctx, room.ID)
+		if err != nil {
+			t.Fatalf("GetRoom error: %v", err)
+		}
+		if room.Status != RoomStatusPlaying {
+			t.Errorf("expected room to stay playing, got %s", room.Status)
+		}
+	})
+}
@@ -3,35 +3,167 @@ package lib
 import (
 	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 )
 
+// HandlerFunc is an HTTP handler that may fail. A returned *Error is sent
+// to the client as is; any other error is reported as a 500. A handler
+// that returns nil without writing anything sends an empty 200.
 type HandlerFunc func(http.ResponseWriter, *http.Request) error
 
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := trackResponse(w)
+	err := h(rw, r)
+	switch {
+	case err != nil && rw.started():
+		// Too late to tell the client; the status is already out.
+		slog.ErrorContext(r.Context(), "Handler failed after responding", "status", rw.statusCode, "error", err)
+	case err != nil:
+		WriteError(rw, r, err)
+	case !rw.started():
+		rw.WriteHeader(http.StatusOK)
+	}
+}
+
+// Middleware wraps a HandlerFunc, as RequireAuthMiddleware does.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// ServeMux routes "METHOD /path" patterns, with path values, to handlers.
+// A path registered for some methods answers others with a 405 listing
+// the allowed ones in the Allow header. Groups share the routes of the mux
+// they come from and add a path prefix and middleware to what they
+// register.
 type ServeMux struct {
+	routes     *routes
+	prefix     string
+	middleware []Middleware
+}
+
+type routes struct {
 	mux *http.ServeMux
+
+	mu sync.Mutex
+	// methods lists the methods registered for each path that has a 405
+	// fallback. open holds paths registered for every method.
+	methods map[string][]string
+	open    map[string]bool
 }
 
 func NewServeMux() *ServeMux {
 	return &ServeMux{
-		mux: http.NewServeMux(),
+		routes: &routes{
+			mux:     http.NewServeMux(),
+			methods: make(map[string][]string),
+			open:    make(map[string]bool),
+		},
 	}
 }
 
-func (m *ServeMux) Handle(pattern string, handler http.Handler) {
-	m.mux.Handle(pattern, handler)
+// Group returns a mux registering under prefix, with mw applied outside
+// any route middleware and inside that of m.
+func (m *ServeMux) Group(prefix string, mw ...Middleware) *ServeMux {
+	return &ServeMux{
+		routes:     m.routes,
+		prefix:     m.path(prefix),
+		middleware: append(slices.Clip(m.middleware), mw...),
+	}
 }
 
-func (m *ServeMux) HandleFunc(pattern string, handler HandlerFunc) {
-	m.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
-		if err := handler(w, r); err != nil {
-			slog.ErrorContext(r.Context(), "internal server error", "error", err)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
+func (m *ServeMux) Handle(pattern string, handler http.Handler) {
+	if len(m.middleware) == 0 {
+		m.register(pattern, handler)
+		return
+	}
+	m.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) error {
+		handler.ServeHTTP(w, r)
+		return nil
 	})
 }
 
+// HandleFunc registers handler for pattern, wrapped in mw and then in the
+// middleware of the group.
+func (m *ServeMux) HandleFunc(pattern string, handler HandlerFunc, mw ...Middleware) {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	for i := len(m.middleware) - 1; i >= 0; i-- {
+		handler = m.middleware[i](handler)
+	}
+	m.register(pattern, handler)
+}
+
+func (m *ServeMux) Get(path string, handler HandlerFunc, mw ...Middleware) {
+	m.HandleFunc(http.MethodGet+" "+path, handler, mw...)
+}
+
+func (m *ServeMux) Post(path string, handler HandlerFunc, mw ...Middleware) {
+	m.HandleFunc(http.MethodPost+" "+path, handler, mw...)
+}
+
+func (m *ServeMux) Put(path string, handler HandlerFunc, mw ...Middleware) {
+	m.HandleFunc(http.MethodPut+" "+path, handler, mw...)
+}
+
+func (m *ServeMux) Patch(path string, handler HandlerFunc, mw ...Middleware) {
+	m.HandleFunc(http.MethodPatch+" "+path, handler, mw...)
+}
+
+func (m *ServeMux) Delete(path string, handler HandlerFunc, mw ...Middleware) {
+	m.HandleFunc(http.MethodDelete+" "+path, handler, mw...)
+}
+
 func (m *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	m.mux.ServeHTTP(w, r)
+	m.routes.mux.ServeHTTP(w, r)
+}
+
+func (m *ServeMux) path(p string) string {
+	if m.prefix == "" {
+		return p
+	}
+	return strings.TrimSuffix(m.prefix, "/") + p
+}
+
+func (m *ServeMux) register(pattern string, handler http.Handler) {
+	method, p, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, p = "", pattern
+	}
+	p = m.path(strings.TrimLeft(p, " "))
+	rt := m.routes
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if method == "" {
+		rt.mux.Handle(p, handler)
+		rt.open[p] = true
+		return
+	}
+	rt.mux.Handle(method+" "+p, handler)
+	if rt.open[p] {
+		return
+	}
+	if _, ok := rt.methods[p]; !ok {
+		rt.mux.Handle(p, rt.methodNotAllowed(p))
+	}
+	rt.methods[p] = append(rt.methods[p], method)
+}
+
+// methodNotAllowed answers requests to p with a method nothing is
+// registered for.
+func (rt *routes) methodNotAllowed(p string) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		rt.mu.Lock()
+		allowed := slices.Clone(rt.methods[p])
+		rt.mu.Unlock()
+
+		if slices.Contains(allowed, http.MethodGet) && !slices.Contains(allowed, http.MethodHead) {
+			allowed = append(allowed, http.MethodHead)
+		}
+		slices.Sort(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		return NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not allowed here")
+	}
 }
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeMuxRouting(t *testing.T) {
+	mux := NewServeMux()
+	reply := func(body string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			_, err := w.Write([]byte(body + r.PathValue("id")))
+			return err
+		}
+	}
+	mux.Get("/items/{id}", reply("get "))
+	mux.Post("/items/{id}", reply("post "))
+	mux.Delete("/items", reply("delete"))
+	mux.HandleFunc("/any", reply("any"))
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	t.Run("PathValue", func(t *testing.T) {
+		if w := do(http.MethodPost, "/items/7"); w.Code != http.StatusOK || w.Body.String() != "post 7" {
+			t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Head", func(t *testing.T) {
+		if w := do(http.MethodHead, "/items/7"); w.Code != http.StatusOK {
+			t.Errorf("expected HEAD to be served by GET, got %d", w.Code)
+		}
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		w := do(http.MethodPut, "/items/7")
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected status 405, got %d", w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != "GET, HEAD, POST" {
+			t.Errorf("unexpected Allow header %q", got)
+		}
+		var resp ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode error: %v", err)
+		}
+		if resp.Error.Code != CodeMethodNotAllowed {
+			t.Errorf("expected method_not_allowed, got %+v", resp.Error)
+		}
+
+		if got := do(http.MethodGet, "/items").Header().Get("Allow"); got != "DELETE" {
+			t.Errorf("unexpected Allow header %q", got)
+		}
+	})
+
+	t.Run("AnyMethod", func(t *testing.T) {
+		if w := do(http.MethodPatch, "/any"); w.Code != http.StatusOK {
+			t.Errorf("expected a route without method to take any method, got %d", w.Code)
+		}
+	})
+}
+
+func TestServeMuxGroups(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) error {
+				calls = append(calls, name)
+				return next(w, r)
+			}
+		}
+	}
+	deny := func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			return NewError(http.StatusForbidden, CodeForbidden, "Admins only")
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		calls = append(calls, "handler")
+		return nil
+	}
+
+	mux := NewServeMux()
+	api := mux.Group("/api", record("api"))
+	api.Get("/rooms", handler, record("route"))
+	api.Group("/v2", record("v2")).Get("/rooms", handler)
+	api.Group("/admin", deny).Get("/users", handler)
+
+	for _, tt := range []struct {
+		target string
+		status int
+		calls  string
+	}{
+		{"/api/rooms", http.StatusOK, "api route handler"},
+		{"/api/v2/rooms", http.StatusOK, "api v2 handler"},
+		{"/api/admin/users", http.StatusForbidden, "api"},
+		{"/rooms", http.StatusNotFound, ""},
+	} {
+		calls = nil
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.status, w.Code)
+		}
+		if got := strings.Join(calls, " "); got != tt.calls {
+			t.Errorf("%s: expected calls %q, got %q", tt.target, tt.calls, got)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewOriginChecker returns a CheckOrigin function for websocket.Upgrader.
+// Requests without an Origin header (non-browser clients) and same-origin
+// requests are accepted, as are origins in allowed. In dev mode every
+// origin is accepted.
+func NewOriginChecker(allowed []string, devMode bool) func(r *http.Request) bool {
+	allowlist := make(map[string]bool, len(allowed))
+	for _, origin := range allowed {
+		allowlist[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return func(r *http.Request) bool {
+		if devMode {
+			return true
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return allowlist[strings.ToLower(u.Scheme+"://"+u.Host)]
+	}
+}
@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginChecker(t *testing.T) {
+	check := NewOriginChecker([]string{"https://puyo.example.com", "http://localhost:3000/"}, false)
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"NoOrigin", "", true},
+		{"SameOrigin", "http://game.internal:8080", true},
+		{"Allowed", "https://puyo.example.com", true},
+		{"AllowedCaseInsensitive", "HTTPS://Puyo.Example.com", true},
+		{"AllowedWithTrailingSlash", "http://localhost:3000", true},
+		{"WrongScheme", "http://puyo.example.com", false},
+		{"WrongPort", "https://puyo.example.com:8443", false},
+		{"Evil", "https://evil.example.com", false},
+		{"Malformed", "://nope", false},
+		{"Null", "null", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://game.internal:8080/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := check(req); got != tt.want {
+				t.Errorf("expected %v for origin %q, got %v", tt.want, tt.origin, got)
+			}
+		})
+	}
+
+	t.Run("DevMode", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://game.internal:8080/ws", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		if !NewOriginChecker(nil, true)(req) {
+			t.Error("expected dev mode to accept any origin")
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sodefrin/PP/server/db"
+)
+
+// MaxPasswordBytes is bcrypt's input limit. Longer passwords cannot be
+// hashed.
+const MaxPasswordBytes = 72
+
+// DefaultResetTTL is how long a password reset token stays valid.
+const DefaultResetTTL = 24 * time.Hour
+
+// ErrInvalidResetToken is returned for reset tokens that are unknown,
+// already used or expired.
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordPolicy decides which passwords may be set. It applies at signup,
+// password change and reset, not at sign-in, so tightening it does not
+// lock anyone out.
+type PasswordPolicy struct {
+	// MinLength is the minimum length in characters.
+	MinLength int
+	// Breached holds passwords known from breaches, which are refused
+	// whatever their length.
+	Breached map[string]struct{}
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 8}
+}
+
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength < 1 || p.MinLength > MaxPasswordBytes {
+		return fmt.Errorf("password minimum length must be between 1 and %d", MaxPasswordBytes)
+	}
+	return nil
+}
+
+// Check returns a 400 reporting field if password breaks the policy.
+func (p PasswordPolicy) Check(field, password string) error {
+	apiErr := BadRequest("Password does not meet the policy")
+	switch {
+	case utf8.RuneCountInString(password) < p.MinLength:
+		return apiErr.WithField(field, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	case len(password) > MaxPasswordBytes:
+		return apiErr.WithField(field, fmt.Sprintf("must be at most %d bytes", MaxPasswordBytes))
+	}
+	if _, ok := p.Breached[password]; ok {
+		return apiErr.WithField(field, "appears in a list of breached passwords")
+	}
+	return nil
+}
+
+// LoadBreachedPasswords reads a breached password list with one password
+// per line. Blank lines are skipped; nothing else is, since any line may
+// be someone's password.
+func LoadBreachedPasswords(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	breached := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			breached[line] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return breached, nil
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches the hash of user.
+func CheckPassword(user db.User, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// SetPassword stores a new password hash for userID. Every session of the
+// user except keepSessionID is revoked, as are pending reset tokens.
+func SetPassword(ctx context.Context, conn *sql.DB, queries *db.Queries, userID int64, hash, keepSessionID string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := setPassword(ctx, queries.WithTx(tx), userID, hash, keepSessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setPassword(ctx context.Context, qtx *db.Queries, userID int64, hash, keepSessionID string) error {
+	if err := qtx.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{PasswordHash: hash, ID: userID}); err != nil {
+		return err
+	}
+	if err := qtx.DeletePasswordResetsByUserID(ctx, userID); err != nil {
+		return err
+	}
+	return qtx.DeleteOtherSessions(ctx, db.DeleteOtherSessionsParams{UserID: userID, ID: keepSessionID})
+}
+
+// CreatePasswordReset returns a one-time token that resets the password of
+// userID within ttl. Tokens issued earlier for the user stop working.
+func CreatePasswordReset(ctx context.Context, conn *sql.DB, queries *db.Queries, userID int64, ttl time.Duration) (string, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.DeletePasswordResetsByUserID(ctx, userID); err != nil {
+		return "", err
+	}
+	token := rand.Text()
+	// Times are stored as text; keep them in UTC so they compare correctly.
+	now := time.Now().UTC()
+	if err := qtx.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		TokenHash: hashResetToken(token),
+		UserID:    userID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}); err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+// ResetPassword uses up token and sets the password of its user to hash,
+// signing them out everywhere. It returns ErrInvalidResetToken if the
+// token cannot be used.
+func ResetPassword(ctx context.Context, conn *sql.DB, queries *db.Queries, token, hash string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := queries.WithTx(tx)
+
+	reset, err := qtx.DeletePasswordReset(ctx, hashResetToken(token))
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+	if err := setPassword(ctx, qtx, reset.UserID, hash, ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hashResetToken is what is stored for token, so that a copy of the
+// database cannot be used to reset passwords.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
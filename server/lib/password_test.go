@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/db"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, Breached: map[string]struct{}{"password123": {}}}
+	tests := []struct {
+		name     string
+		password string
+		message  string
+	}{
+		{"OK", "correct horse", ""},
+		{"TooShort", "short", "must be at least 8 characters"},
+		{"CountsCharacters", "ぱすわーどです", "must be at least 8 characters"},
+		{"TooLong", strings.Repeat("x", 73), "must be at most 72 bytes"},
+		{"Breached", "password123", "appears in a list of breached passwords"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check("password", tt.password)
+			if tt.message == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 {
+				t.Fatalf("expected a field error, got %v", err)
+			}
+			if f := apiErr.Fields[0]; f.Field != "password" || f.Message != tt.message {
+				t.Errorf("expected %q, got %+v", tt.message, f)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	if err := DefaultPasswordPolicy().Validate(); err != nil {
+		t.Errorf("expected the default policy to be valid, got %v", err)
+	}
+	for _, n := range []int{0, 73} {
+		if err := (PasswordPolicy{MinLength: n}).Validate(); err == nil {
+			t.Errorf("expected minimum length %d to be rejected", n)
+		}
+	}
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("123456\r\n\n# not a comment\nqwerty\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	breached, err := LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords error: %v", err)
+	}
+	if len(breached) != 3 {
+		t.Errorf("expected 3 passwords, got %v", breached)
+	}
+	for _, p := range []string{"123456", "# not a comment", "qwerty"} {
+		if _, ok := breached[p]; !ok {
+			t.Errorf("expected %q to be listed", p)
+		}
+	}
+
+	if _, err := LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected a missing list to fail")
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	conn, queries := newTestDB(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	for _, id := range []string{"a", "b"} {
+		if _, err := queries.CreateSession(ctx, db.CreateSessionParams{
+			ID: id, UserID: 1, ExpiresAt: now.Add(time.Hour), CreatedAt: now, LastSeenAt: now,
+		}); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	first, err := CreatePasswordReset(ctx, conn, queries, 1, time.Hour)
+	if err != nil {
+		t.Fatalf("CreatePasswordReset error: %v", err)
+	}
+	token, err := CreatePasswordReset(ctx, conn, queries, 1, time.Hour)
+	if err != nil {
+		t.Fatalf("CreatePasswordReset error: %v", err)
+	}
+	if err := ResetPassword(ctx, conn, queries, first, "first"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("expected the replaced token to be rejected, got %v", err)
+	}
+
+	if err := ResetPassword(ctx, conn, queries, token, "new-hash"); err != nil {
+		t.Fatalf("ResetPassword error: %v", err)
+	}
+	user, err := queries.GetUser(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetUser error: %v", err)
+	}
+	if user.PasswordHash != "new-hash" {
+		t.Errorf("expected the password to change, got %q", user.PasswordHash)
+	}
+	for _, id := range []string{"a", "b"} {
+		if _, err := queries.GetSession(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected session %s to be revoked, got %v", id, err)
+		}
+	}
+
+	if err := ResetPassword(ctx, conn, queries, token, "again"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("expected a used token to be rejected, got %v", err)
+	}
+
+	expired, err := CreatePasswordReset(ctx, conn, queries, 1, -time.Minute)
+	if err != nil {
+		t.Fatalf("CreatePasswordReset error: %v", err)
+	}
+	if err := ResetPassword(ctx, conn, queries, expired, "late"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("expected an expired token to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// responseWriter records the status of the response once it is started,
+// so that middleware can log it and ServeMux knows not to write another.
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// trackResponse wraps w, reusing it if it already tracks the response.
+func trackResponse(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+// started reports whether a status has been sent or the connection taken
+// over.
+func (rw *responseWriter) started() bool {
+	return rw.statusCode != 0
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusOK
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Hijack lets websocket upgrades through the wrapper.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func (rw *responseWriter) Flush() {
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// WriteJSON sends v as a JSON body with status.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// NoContent sends an empty 204 response.
+func NoContent(w http.ResponseWriter) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Redirect sends the client to url with code, which must be a 3xx status.
+func Redirect(w http.ResponseWriter, r *http.Request, url string, code int) error {
+	http.Redirect(w, r, url, code)
+	return nil
+}
@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// headerCounter counts WriteHeader calls reaching the real writer.
+type headerCounter struct {
+	*httptest.ResponseRecorder
+	calls int
+}
+
+func (h *headerCounter) WriteHeader(code int) {
+	h.calls++
+	h.ResponseRecorder.WriteHeader(code)
+}
+
+func TestHandlerFuncWritesOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler HandlerFunc
+		status  int
+	}{
+		{"Created", func(w http.ResponseWriter, r *http.Request) error {
+			return WriteJSON(w, http.StatusCreated, map[string]int{"id": 1})
+		}, http.StatusCreated},
+		{"BodyOnly", func(w http.ResponseWriter, r *http.Request) error {
+			_, err := w.Write([]byte("OK"))
+			return err
+		}, http.StatusOK},
+		{"Nothing", func(w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}, http.StatusOK},
+		{"NoContent", func(w http.ResponseWriter, r *http.Request) error {
+			return NoContent(w)
+		}, http.StatusNoContent},
+		{"FailedAfterResponding", func(w http.ResponseWriter, r *http.Request) error {
+			_ = NoContent(w)
+			return errors.New("too late")
+		}, http.StatusNoContent},
+		{"Redirect", func(w http.ResponseWriter, r *http.Request) error {
+			return Redirect(w, r, "/elsewhere", http.StatusSeeOther)
+		}, http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &headerCounter{ResponseRecorder: httptest.NewRecorder()}
+			tt.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if w.calls > 1 {
+				t.Errorf("expected at most one WriteHeader, got %d", w.calls)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := WriteJSON(w, http.StatusAccepted, map[string]string{"name": "alice"}); err != nil {
+		t.Fatalf("WriteJSON error: %v", err)
+	}
+	if w.Code != http.StatusAccepted || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if got := w.Body.String(); got != `{"name":"alice"}` {
+		t.Errorf("unexpected body %s", got)
+	}
+
+	if err := WriteJSON(httptest.NewRecorder(), http.StatusOK, func() {}); err == nil {
+		t.Error("expected error for a value that cannot be encoded")
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	// gorilla/websocket asserts http.Hijacker rather than unwrapping.
+	handler := LoggingMiddleware(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			return errors.New("not a Hijacker")
+		}
+		conn, brw, err := h.Hijack()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = brw.WriteString("HTTP/1.1 418 I'm a teapot\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		return brw.Flush()
+	}))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("expected the hijacked response, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,36 @@
+package lib
+
+import (
+	"fmt"
+)
+
+const (
+	RoomStatusWaiting  = "waiting"
+	RoomStatusPlaying  = "playing"
+	RoomStatusFinished = "finished"
+)
+
+var roomTransitions = map[string][]string{
+	RoomStatusWaiting: {RoomStatusPlaying, RoomStatusFinished},
+	RoomStatusPlaying: {RoomStatusFinished},
+}
+
+type RoomTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *RoomTransitionError) Error() string {
+	return fmt.Sprintf("invalid room transition from %q to %q", e.From, e.To)
+}
+
+// ValidateRoomTransition checks that a room may move from one status to
+// another: waiting -> playing -> finished, or waiting -> finished.
+func ValidateRoomTransition(from, to string) error {
+	for _, next := range roomTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &RoomTransitionError{From: from, To: to}
+}
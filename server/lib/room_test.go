@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestValidateRoomTransition(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{RoomStatusWaiting, RoomStatusPlaying, false},
+		{RoomStatusWaiting, RoomStatusFinished, false},
+		{RoomStatusPlaying, RoomStatusFinished, false},
+		{RoomStatusPlaying, RoomStatusWaiting, true},
+		{RoomStatusFinished, RoomStatusPlaying, true},
+		{RoomStatusFinished, RoomStatusFinished, true},
+		{"unknown", RoomStatusPlaying, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			err := ValidateRoomTransition(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
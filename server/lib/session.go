@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sodefrin/PP/server/db"
+)
+
+// sessionTouchInterval throttles renewals so an active session is written
+// at most once a minute rather than on every request.
+const sessionTouchInterval = time.Minute
+
+// SessionPolicy controls how long sign-in sessions stay valid.
+type SessionPolicy struct {
+	// AbsoluteTimeout caps the lifetime of a session from sign-in, however
+	// active it is.
+	AbsoluteTimeout time.Duration
+	// IdleTimeout expires a sliding session that has not been used for
+	// this long. It is ignored unless Sliding is set.
+	IdleTimeout time.Duration
+	// Sliding extends the expiry of a session each time it is used, up to
+	// AbsoluteTimeout. Without it a session expires AbsoluteTimeout after
+	// sign-in.
+	Sliding bool
+}
+
+func DefaultSessionPolicy() SessionPolicy {
+	return SessionPolicy{
+		AbsoluteTimeout: 24 * time.Hour,
+		IdleTimeout:     2 * time.Hour,
+	}
+}
+
+func (p SessionPolicy) Validate() error {
+	if p.AbsoluteTimeout <= 0 {
+		return errors.New("session absolute timeout must be positive")
+	}
+	if p.Sliding && p.IdleTimeout <= 0 {
+		return errors.New("session idle timeout must be positive when sliding")
+	}
+	return nil
+}
+
+// Expiry returns when a session created at createdAt and last used at
+// lastSeenAt expires.
+func (p SessionPolicy) Expiry(createdAt, lastSeenAt time.Time) time.Time {
+	deadline := createdAt.Add(p.AbsoluteTimeout)
+	if p.Sliding {
+		if idle := lastSeenAt.Add(p.IdleTimeout); idle.Before(deadline) {
+			return idle
+		}
+	}
+	return deadline
+}
+
+// CreateSession stores a new session for userID and sets its cookie on w.
+func CreateSession(ctx context.Context, w http.ResponseWriter, queries *db.Queries, policy SessionPolicy, userID int64) (db.Session, error) {
+	// Times are stored as text, so keep them in UTC for the expiry sweep
+	// to compare correctly.
+	now := time.Now().UTC()
+	session, err := queries.CreateSession(ctx, db.CreateSessionParams{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		ExpiresAt:  policy.Expiry(now, now),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		return db.Session{}, err
+	}
+
+	// The cookie lives until the absolute deadline; the server decides
+	// whether an idle session is still valid.
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    session.ID,
+		Expires:  now.Add(policy.AbsoluteTimeout),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+	})
+	return session, nil
+}
+
+// RenewSession records a use of a sliding session at now and extends its
+// expiry. It reports whether the session was written; non-sliding sessions
+// and sessions renewed within the last minute are returned unchanged.
+func RenewSession(ctx context.Context, queries *db.Queries, policy SessionPolicy, session db.Session, now time.Time) (db.Session, bool, error) {
+	if !policy.Sliding || now.Sub(session.LastSeenAt) < sessionTouchInterval {
+		return session, false, nil
+	}
+
+	now = now.UTC()
+	session.LastSeenAt = now
+	session.ExpiresAt = policy.Expiry(session.CreatedAt, now)
+	if err := queries.TouchSession(ctx, db.TouchSessionParams{
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+		ID:         session.ID,
+	}); err != nil {
+		return session, false, err
+	}
+	return session, true, nil
+}
+
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+	})
+}
+
+// SweepSessions deletes expired sessions every interval until ctx is done.
+func SweepSessions(ctx context.Context, queries *db.Queries, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := queries.DeleteExpiredSessions(ctx, time.Now().UTC())
+			if err != nil {
+				slog.ErrorContext(ctx, "DeleteExpiredSessions error", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Swept expired sessions", "count", n)
+			}
+		}
+	}
+}
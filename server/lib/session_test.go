@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sodefrin/PP/server/db"
+
+	_ "modernc.org/sqlite"
+)
+
+// newTestDB opens a private in-memory database with one user.
+func newTestDB(t *testing.T) (*sql.DB, *db.Queries) {
+	t.Helper()
+	dbConn, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = dbConn.Close() })
+
+	if err := db.Migrate(context.Background(), dbConn); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	queries := db.New(dbConn)
+	if _, err := queries.CreateUser(context.Background(), db.CreateUserParams{Name: "session", PasswordHash: "x"}); err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	return dbConn, queries
+}
+
+func TestSessionPolicyExpiry(t *testing.T) {
+	created := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		policy   SessionPolicy
+		lastSeen time.Time
+		want     time.Time
+	}{
+		{"Fixed", SessionPolicy{AbsoluteTimeout: 24 * time.Hour, IdleTimeout: time.Hour}, created.Add(3 * time.Hour), created.Add(24 * time.Hour)},
+		{"SlidingIdle", SessionPolicy{AbsoluteTimeout: 24 * time.Hour, IdleTimeout: time.Hour, Sliding: true}, created.Add(3 * time.Hour), created.Add(4 * time.Hour)},
+		{"SlidingCapped", SessionPolicy{AbsoluteTimeout: 24 * time.Hour, IdleTimeout: time.Hour, Sliding: true}, created.Add(23*time.Hour + 30*time.Minute), created.Add(24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Expiry(created, tt.lastSeen); !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSessionPolicyValidate(t *testing.T) {
+	if err := DefaultSessionPolicy().Validate(); err != nil {
+		t.Errorf("expected default policy to be valid, got %v", err)
+	}
+	if err := (SessionPolicy{}).Validate(); err == nil {
+		t.Error("expected error for zero absolute timeout")
+	}
+	if err := (SessionPolicy{AbsoluteTimeout: time.Hour, Sliding: true}).Validate(); err == nil {
+		t.Error("expected error for sliding without idle timeout")
+	}
+}
+
+func TestRenewSession(t *testing.T) {
+	_, queries := newTestDB(t)
+	ctx := context.Background()
+	policy := SessionPolicy{AbsoluteTimeout: 24 * time.Hour, IdleTimeout: time.Hour, Sliding: true}
+
+	session, err := CreateSession(ctx, httptest.NewRecorder(), queries, policy, 1)
+	if err != nil {
+		t.Fatalf("CreateSession error: %v", err)
+	}
+
+	t.Run("Throttled", func(t *testing.T) {
+		_, renewed, err := RenewSession(ctx, queries, policy, session, session.LastSeenAt.Add(time.Second))
+		if err != nil {
+			t.Fatalf("RenewSession error: %v", err)
+		}
+		if renewed {
+			t.Error("expected renewal within a minute to be skipped")
+		}
+	})
+
+	t.Run("NotSliding", func(t *testing.T) {
+		fixed := policy
+		fixed.Sliding = false
+		_, renewed, err := RenewSession(ctx, queries, fixed, session, session.LastSeenAt.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("RenewSession error: %v", err)
+		}
+		if renewed {
+			t.Error("expected fixed session not to be renewed")
+		}
+	})
+
+	t.Run("Extends", func(t *testing.T) {
+		now := session.LastSeenAt.Add(30 * time.Minute)
+		if _, renewed, err := RenewSession(ctx, queries, policy, session, now); err != nil || !renewed {
+			t.Fatalf("expected renewal, got renewed=%v err=%v", renewed, err)
+		}
+
+		stored, err := queries.GetSession(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSession error: %v", err)
+		}
+		if want := now.Add(time.Hour); !stored.ExpiresAt.Equal(want) {
+			t.Errorf("expected expiry %v, got %v", want, stored.ExpiresAt)
+		}
+	})
+}
+
+func TestSweepSessions(t *testing.T) {
+	_, queries := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now().UTC()
+	for id, expires := range map[string]time.Time{"expired": now.Add(-time.Minute), "live": now.Add(time.Hour)} {
+		if _, err := queries.CreateSession(ctx, db.CreateSessionParams{
+			ID:         id,
+			UserID:     1,
+			ExpiresAt:  expires,
+			CreatedAt:  now.Add(-time.Hour),
+			LastSeenAt: now.Add(-time.Hour),
+		}); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	go SweepSessions(ctx, queries, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := queries.GetSession(ctx, "expired"); errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected expired session to be swept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := queries.GetSession(ctx, "live"); err != nil {
+		t.Errorf("expected live session to survive, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBodyBytes bounds the request bodies read by DecodeJSON.
+const MaxBodyBytes = 64 << 10
+
+// DecodeJSON reads the JSON body of r into v, a pointer to a struct, and
+// validates it with Validate. An empty body decodes as {}, leaving it to
+// the validate tags whether that is acceptable. Unknown fields, trailing
+// data and bodies over MaxBodyBytes are rejected.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&json.RawMessage{}); err != io.EOF {
+		if err != nil {
+			return decodeError(err)
+		}
+		return BadRequest("Request body must be a single JSON object")
+	}
+	return Validate(v)
+}
+
+func decodeError(err error) error {
+	var maxErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxErr):
+		return NewError(http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+			fmt.Sprintf("Request body is larger than %d bytes", maxErr.Limit))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest("Request body is not valid JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return BadRequest("Invalid request body").WithField(typeErr.Field, "must be a "+typeErr.Type.String())
+	}
+	// encoding/json has no error type for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		name, _ := strconv.Unquote(field)
+		return BadRequest("Invalid request body").WithField(name, "unknown field")
+	}
+	return BadRequest("Invalid request body")
+}
+
+// Validate checks the fields of v, a struct or a pointer to one, against
+// their validate tags and reports every failing field in one 400 error.
+// Fields are named by their json tag. Rules are separated by commas:
+//
+//	required      a string must not be empty; without it the other rules
+//	              only apply to non-empty strings
+//	min=N, max=N  bounds on the length of a string in characters, or on
+//	              the value of an integer
+//	maxbytes=N    bound on the length of a string in bytes
+//	charset=NAME  every character of a string is in the named charset
+//	oneof=A B     a string is one of the listed values
+func Validate(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []FieldError
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		if msg := validateField(rv.Field(i), strings.Split(tag, ",")); msg != "" {
+			fields = append(fields, FieldError{Field: jsonName(sf), Message: msg})
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    CodeInvalidRequest,
+		Message: "Invalid request body",
+		Fields:  fields,
+	}
+}
+
+// charsets are the sets a charset rule may name.
+var charsets = map[string]struct {
+	allowed     func(rune) bool
+	description string
+}{
+	"name": {
+		allowed: func(r rune) bool {
+			return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.", r))
+		},
+		description: "ASCII letters, digits, _, - and .",
+	},
+}
+
+// validateField returns why v breaks rules, or "" if it does not. Broken
+// tags are programming errors and panic.
+func validateField(v reflect.Value, rules []string) string {
+	if v.Kind() == reflect.String && v.Len() == 0 {
+		if slices.Contains(rules, "required") {
+			return "required"
+		}
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+		case "min", "max", "maxbytes":
+			limit, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				panic(fmt.Sprintf("validate: bad limit in %q", rule))
+			}
+			if msg := checkLimit(v, name, limit); msg != "" {
+				return msg
+			}
+		case "charset":
+			cs, ok := charsets[arg]
+			if !ok {
+				panic(fmt.Sprintf("validate: unknown charset %q", arg))
+			}
+			if strings.IndexFunc(v.String(), func(r rune) bool { return !cs.allowed(r) }) >= 0 {
+				return "may only contain " + cs.description
+			}
+		case "oneof":
+			options := strings.Fields(arg)
+			if !slices.Contains(options, v.String()) {
+				return "must be one of " + strings.Join(options, ", ")
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q", rule))
+		}
+	}
+	return ""
+}
+
+func checkLimit(v reflect.Value, rule string, limit int64) string {
+	switch v.Kind() {
+	case reflect.String:
+		n, unit := int64(utf8.RuneCountInString(v.String())), "characters"
+		if rule == "maxbytes" {
+			n, unit = int64(v.Len()), "bytes"
+		}
+		if rule == "min" && n < limit {
+			return fmt.Sprintf("must be at least %d %s", limit, unit)
+		}
+		if rule != "min" && n > limit {
+			return fmt.Sprintf("must be at most %d %s", limit, unit)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rule == "min" && v.Int() < limit {
+			return fmt.Sprintf("must be at least %d", limit)
+		}
+		if rule == "max" && v.Int() > limit {
+			return fmt.Sprintf("must be at most %d", limit)
+		}
+	default:
+		panic(fmt.Sprintf("validate: %s does not apply to %s", rule, v.Kind()))
+	}
+	return ""
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
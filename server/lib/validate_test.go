@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type validated struct {
+	Name  string `json:"name" validate:"required,min=3,max=8,charset=name"`
+	Pass  string `json:"pass" validate:"maxbytes=4"`
+	Kind  string `json:"kind" validate:"oneof=a b"`
+	Count int    `json:"count" validate:"min=1,max=9"`
+	Free  string `json:"free"`
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  validated
+		fields map[string]string
+	}{
+		{"Valid", validated{Name: "bob", Count: 1}, nil},
+		{"OptionalEmpty", validated{Name: "bob", Pass: "", Kind: "", Count: 9}, nil},
+		{"Required", validated{Count: 1}, map[string]string{"name": "required"}},
+		{"TooShort", validated{Name: "bo", Count: 1}, map[string]string{"name": "must be at least 3 characters"}},
+		{"TooLong", validated{Name: "bobbobbob", Count: 1}, map[string]string{"name": "must be at most 8 characters"}},
+		{"RunesNotBytes", validated{Name: "bob", Pass: "ééé", Count: 1}, map[string]string{"pass": "must be at most 4 bytes"}},
+		{"Charset", validated{Name: "bob bob", Count: 1}, map[string]string{"name": "may only contain ASCII letters, digits, _, - and ."}},
+		{"NonASCII", validated{Name: "böb", Count: 1}, map[string]string{"name": "may only contain ASCII letters, digits, _, - and ."}},
+		{"OneOf", validated{Name: "bob", Kind: "c", Count: 1}, map[string]string{"kind": "must be one of a, b"}},
+		{"IntRange", validated{Name: "bob", Count: 10}, map[string]string{"count": "must be at most 9"}},
+		{"Several", validated{Name: "", Kind: "c", Count: 0}, map[string]string{
+			"name":  "required",
+			"kind":  "must be one of a, b",
+			"count": "must be at least 1",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.value)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || apiErr.Code != CodeInvalidRequest {
+				t.Fatalf("expected a 400 invalid_request, got %v", err)
+			}
+			got := make(map[string]string)
+			for _, f := range apiErr.Fields {
+				got[f.Field] = f.Message
+			}
+			if len(got) != len(tt.fields) {
+				t.Errorf("expected fields %v, got %v", tt.fields, got)
+			}
+			for field, msg := range tt.fields {
+				if got[field] != msg {
+					t.Errorf("%s: expected %q, got %q", field, msg, got[field])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBadTag(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected an unknown rule to panic")
+		}
+	}()
+	_ = Validate(struct {
+		Name string `validate:"shiny"`
+	}{Name: "x"})
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type request struct {
+		Name  string `json:"name" validate:"max=8"`
+		Count int    `json:"count"`
+	}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		field  string
+	}{
+		{"Valid", `{"name":"bob","count":2}`, 0, ""},
+		{"Empty", ``, 0, ""},
+		{"Invalid", `{"name":`, http.StatusBadRequest, ""},
+		{"Trailing", `{"name":"bob"} {}`, http.StatusBadRequest, ""},
+		{"UnknownField", `{"name":"bob","admin":true}`, http.StatusBadRequest, "admin"},
+		{"WrongType", `{"count":"two"}`, http.StatusBadRequest, "count"},
+		{"Validated", `{"name":"bobbobbob"}`, http.StatusBadRequest, "name"},
+		{"TooLarge", `{"name":"` + strings.Repeat("a", MaxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req request
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			err := DecodeJSON(httptest.NewRecorder(), r, &req)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.Status != tt.status {
+				t.Fatalf("expected status %d, got %v", tt.status, err)
+			}
+			if tt.field != "" && (len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != tt.field) {
+				t.Errorf("expected %s to be reported, got %+v", tt.field, apiErr.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package matchmaking
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// Interval is how often the matcher looks for pairs. Rating windows grow
+// much more slowly, so waiting players are not kept waiting by it.
+const Interval = time.Second
+
+// Matcher turns pairs taken from a Queue into rooms.
+type Matcher struct {
+	queue   *Queue
+	conn    *sql.DB
+	queries *db.Queries
+	// onMatch is called with every room created, typically to tell both
+	// players over their websocket.
+	onMatch func(ctx context.Context, room db.Room)
+}
+
+func NewMatcher(queue *Queue, conn *sql.DB, queries *db.Queries, onMatch func(context.Context, db.Room)) *Matcher {
+	return &Matcher{queue: queue, conn: conn, queries: queries, onMatch: onMatch}
+}
+
+// Run matches the queue every interval until ctx is done.
+func (m *Matcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.MatchOnce(ctx)
+		}
+	}
+}
+
+// MatchOnce creates a room for every pair that can be made now and
+// returns the rooms created.
+func (m *Matcher) MatchOnce(ctx context.Context) []db.Room {
+	var rooms []db.Room
+	for _, pair := range m.queue.Match() {
+		room, ok, err := m.createRoom(ctx, pair)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to create matched room", "p1_id", pair.P1.UserID, "p2_id", pair.P2.UserID, "error", err)
+			m.queue.Requeue(pair.P1)
+			m.queue.Requeue(pair.P2)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		slog.InfoContext(ctx, "Matched players", "room_id", room.ID, "p1_id", pair.P1.UserID, "p2_id", pair.P2.UserID)
+		rooms = append(rooms, room)
+		if m.onMatch != nil {
+			m.onMatch(ctx, room)
+		}
+	}
+	return rooms
+}
+
+// createRoom seats both players of pair in a new playing room. A player
+// who joined another room while queued is dropped from matchmaking and
+// the other one is put back in the queue; ok is false in that case.
+func (m *Matcher) createRoom(ctx context.Context, pair Pair) (room db.Room, ok bool, err error) {
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Room{}, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := m.queries.WithTx(tx)
+
+	busy1, err := inActiveRoom(ctx, qtx, pair.P1.UserID)
+	if err != nil {
+		return db.Room{}, false, err
+	}
+	busy2, err := inActiveRoom(ctx, qtx, pair.P2.UserID)
+	if err != nil {
+		return db.Room{}, false, err
+	}
+	if busy1 || busy2 {
+		if !busy1 {
+			m.queue.Requeue(pair.P1)
+		}
+		if !busy2 {
+			m.queue.Requeue(pair.P2)
+		}
+		return db.Room{}, false, nil
+	}
+
+	room, err = qtx.CreateRoom(ctx, db.CreateRoomParams{
+		P1ID:        sql.NullInt64{Int64: pair.P1.UserID, Valid: true},
+		Status:      lib.RoomStatusWaiting,
+		Seed:        rand.Int64(),
+		SharedQueue: true,
+	})
+	if err != nil {
+		return db.Room{}, false, err
+	}
+	room, err = qtx.JoinRoom(ctx, db.JoinRoomParams{
+		P2ID: sql.NullInt64{Int64: pair.P2.UserID, Valid: true},
+		ID:   room.ID,
+	})
+	if err != nil {
+		return db.Room{}, false, err
+	}
+	return room, true, tx.Commit()
+}
+
+func inActiveRoom(ctx context.Context, queries *db.Queries, userID int64) (bool, error) {
+	id := sql.NullInt64{Int64: userID, Valid: true}
+	_, err := queries.GetActiveRoomByUser(ctx, db.GetActiveRoomByUserParams{P1ID: id, P2ID: id})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,99 @@
+package matchmaking
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/lib"
+
+	_ "modernc.org/sqlite"
+)
+
+func newTestDB(t *testing.T) (*sql.DB, *db.Queries) {
+	t.Helper()
+	dbConn, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = dbConn.Close() })
+
+	if err := db.Migrate(context.Background(), dbConn); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return dbConn, db.New(dbConn)
+}
+
+func createTestUser(t *testing.T, queries *db.Queries, name string) db.User {
+	t.Helper()
+	user, err := queries.CreateUser(context.Background(), db.CreateUserParams{Name: name, PasswordHash: "x"})
+	if err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	return user
+}
+
+func TestMatcherCreatesRoom(t *testing.T) {
+	ctx := context.Background()
+	conn, queries := newTestDB(t)
+	alice := createTestUser(t, queries, "alice")
+	bob := createTestUser(t, queries, "bob")
+
+	var notified []db.Room
+	q := NewQueue(DefaultWindow)
+	m := NewMatcher(q, conn, queries, func(_ context.Context, room db.Room) {
+		notified = append(notified, room)
+	})
+	q.Enqueue(alice.ID, alice.Rating)
+	q.Enqueue(bob.ID, bob.Rating)
+
+	rooms := m.MatchOnce(ctx)
+	if len(rooms) != 1 {
+		t.Fatalf("expected 1 room, got %d", len(rooms))
+	}
+	room := rooms[0]
+	if room.P1ID.Int64 != alice.ID || room.P2ID.Int64 != bob.ID {
+		t.Errorf("expected %d vs %d, got %d vs %d", alice.ID, bob.ID, room.P1ID.Int64, room.P2ID.Int64)
+	}
+	if room.Status != lib.RoomStatusPlaying {
+		t.Errorf("expected status playing, got %s", room.Status)
+	}
+	if len(notified) != 1 || notified[0].ID != room.ID {
+		t.Errorf("expected room %d to be announced, got %+v", room.ID, notified)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected empty queue, got %d", q.Len())
+	}
+}
+
+func TestMatcherSkipsBusyPlayer(t *testing.T) {
+	ctx := context.Background()
+	conn, queries := newTestDB(t)
+	alice := createTestUser(t, queries, "alice")
+	bob := createTestUser(t, queries, "bob")
+
+	q := NewQueue(DefaultWindow)
+	m := NewMatcher(q, conn, queries, nil)
+	q.Enqueue(alice.ID, alice.Rating)
+	q.Enqueue(bob.ID, bob.Rating)
+
+	// Bob opened a room while waiting.
+	if _, err := queries.CreateRoom(ctx, db.CreateRoomParams{
+		P1ID:        sql.NullInt64{Int64: bob.ID, Valid: true},
+		Status:      lib.RoomStatusWaiting,
+		SharedQueue: true,
+	}); err != nil {
+		t.Fatalf("CreateRoom error: %v", err)
+	}
+
+	if rooms := m.MatchOnce(ctx); len(rooms) != 0 {
+		t.Fatalf("expected no room, got %d", len(rooms))
+	}
+	if !q.Contains(alice.ID) {
+		t.Error("expected alice to be back in the queue")
+	}
+	if q.Contains(bob.ID) {
+		t.Error("expected bob to be dropped from the queue")
+	}
+}
@@ -0,0 +1,179 @@
+// Package matchmaking pairs queued players of similar rating. The rating
+// window a player accepts widens the longer they wait.
+package matchmaking
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrAlreadyQueued = errors.New("already queued")
+
+// Window is how far apart in rating two players may be to be matched,
+// as a function of how long they have waited.
+type Window struct {
+	Initial int64
+	Step    int64
+	// Every is how often the window grows by Step.
+	Every time.Duration
+	Max   int64
+}
+
+var DefaultWindow = Window{
+	Initial: 50,
+	Step:    50,
+	Every:   5 * time.Second,
+	Max:     1000,
+}
+
+// At returns the window after waiting for waited.
+func (w Window) At(waited time.Duration) int64 {
+	width := w.Initial
+	if w.Every > 0 && waited > 0 {
+		width += w.Step * int64(waited/w.Every)
+	}
+	return min(width, w.Max)
+}
+
+type Entry struct {
+	UserID   int64
+	Rating   int64
+	JoinedAt time.Time
+}
+
+// Pair is two entries removed from the queue together. P1 waited longer.
+type Pair struct {
+	P1 Entry
+	P2 Entry
+}
+
+// Queue holds waiting players in the order they joined. It is safe for
+// concurrent use; a player is handed out in at most one Pair.
+type Queue struct {
+	window Window
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewQueue(window Window) *Queue {
+	return &Queue{window: window, now: time.Now}
+}
+
+func (q *Queue) Enqueue(userID, rating int64) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.indexOf(userID) >= 0 {
+		return Entry{}, ErrAlreadyQueued
+	}
+	e := Entry{UserID: userID, Rating: rating, JoinedAt: q.now()}
+	q.entries = append(q.entries, e)
+	return e, nil
+}
+
+// Requeue puts back an entry that was matched but could not be placed in
+// a room, keeping its original join time.
+func (q *Queue) Requeue(e Entry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.indexOf(e.UserID) >= 0 {
+		return
+	}
+	i := 0
+	for i < len(q.entries) && !q.entries[i].JoinedAt.After(e.JoinedAt) {
+		i++
+	}
+	q.entries = append(q.entries[:i], append([]Entry{e}, q.entries[i:]...)...)
+}
+
+// Dequeue removes userID and reports whether it was queued.
+func (q *Queue) Dequeue(userID int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.indexOf(userID)
+	if i < 0 {
+		return false
+	}
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	return true
+}
+
+func (q *Queue) Contains(userID int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.indexOf(userID) >= 0
+}
+
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Match removes and returns every pair that can be made now. Starting
+// with whoever has waited longest, each player is paired with the closest
+// rated player whose window, like their own, covers the gap.
+func (q *Queue) Match() []Pair {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	matched := make([]bool, len(q.entries))
+	var pairs []Pair
+	for i, a := range q.entries {
+		if matched[i] {
+			continue
+		}
+		windowA := q.window.At(now.Sub(a.JoinedAt))
+		best, bestGap := -1, int64(0)
+		for j := i + 1; j < len(q.entries); j++ {
+			b := q.entries[j]
+			if matched[j] {
+				continue
+			}
+			gap := abs(a.Rating - b.Rating)
+			if gap > windowA || gap > q.window.At(now.Sub(b.JoinedAt)) {
+				continue
+			}
+			if best < 0 || gap < bestGap {
+				best, bestGap = j, gap
+			}
+		}
+		if best >= 0 {
+			matched[i], matched[best] = true, true
+			pairs = append(pairs, Pair{P1: a, P2: q.entries[best]})
+		}
+	}
+
+	if len(pairs) > 0 {
+		remaining := q.entries[:0]
+		for i, e := range q.entries {
+			if !matched[i] {
+				remaining = append(remaining, e)
+			}
+		}
+		q.entries = remaining
+	}
+	return pairs
+}
+
+func (q *Queue) indexOf(userID int64) int {
+	for i, e := range q.entries {
+		if e.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,173 @@
+package matchmaking
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var testWindow = Window{Initial: 50, Step: 50, Every: 10 * time.Second, Max: 300}
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newTestQueue() (*Queue, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	q := NewQueue(testWindow)
+	q.now = clock.now
+	return q, clock
+}
+
+func TestWindowAt(t *testing.T) {
+	tests := []struct {
+		waited time.Duration
+		want   int64
+	}{
+		{0, 50},
+		{9 * time.Second, 50},
+		{10 * time.Second, 100},
+		{35 * time.Second, 200},
+		{time.Hour, 300},
+	}
+	for _, tt := range tests {
+		if got := testWindow.At(tt.waited); got != tt.want {
+			t.Errorf("At(%v): expected %d, got %d", tt.waited, tt.want, got)
+		}
+	}
+}
+
+func TestQueueEnqueueDequeue(t *testing.T) {
+	q, _ := newTestQueue()
+
+	if _, err := q.Enqueue(1, 1500); err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	if _, err := q.Enqueue(1, 1500); err != ErrAlreadyQueued {
+		t.Errorf("expected ErrAlreadyQueued, got %v", err)
+	}
+	if !q.Dequeue(1) {
+		t.Error("expected Dequeue to report the user was queued")
+	}
+	if q.Dequeue(1) {
+		t.Error("expected second Dequeue to report false")
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected empty queue, got %d", q.Len())
+	}
+}
+
+func TestQueueMatch(t *testing.T) {
+	t.Run("ClosestWithinWindow", func(t *testing.T) {
+		q, _ := newTestQueue()
+		q.Enqueue(1, 1500)
+		q.Enqueue(2, 1540)
+		q.Enqueue(3, 1510)
+
+		pairs := q.Match()
+		if len(pairs) != 1 {
+			t.Fatalf("expected 1 pair, got %d", len(pairs))
+		}
+		if pairs[0].P1.UserID != 1 || pairs[0].P2.UserID != 3 {
+			t.Errorf("expected 1 vs 3, got %d vs %d", pairs[0].P1.UserID, pairs[0].P2.UserID)
+		}
+		if !q.Contains(2) || q.Len() != 1 {
+			t.Errorf("expected only user 2 left, got %d entries", q.Len())
+		}
+	})
+
+	t.Run("WindowWidens", func(t *testing.T) {
+		q, clock := newTestQueue()
+		q.Enqueue(1, 1500)
+		q.Enqueue(2, 1620)
+
+		if pairs := q.Match(); len(pairs) != 0 {
+			t.Fatalf("expected no pair yet, got %d", len(pairs))
+		}
+		clock.advance(20 * time.Second)
+		if pairs := q.Match(); len(pairs) != 1 {
+			t.Fatalf("expected 1 pair after waiting, got %d", len(pairs))
+		}
+	})
+
+	t.Run("BothWindowsMustCover", func(t *testing.T) {
+		q, clock := newTestQueue()
+		q.Enqueue(1, 1500)
+		clock.advance(time.Minute)
+		q.Enqueue(2, 1700)
+
+		if pairs := q.Match(); len(pairs) != 0 {
+			t.Fatalf("expected the newcomer's window to block the pair, got %d", len(pairs))
+		}
+		clock.advance(30 * time.Second)
+		if pairs := q.Match(); len(pairs) != 1 {
+			t.Fatalf("expected 1 pair, got %d", len(pairs))
+		}
+	})
+
+	t.Run("RequeueKeepsOrder", func(t *testing.T) {
+		q, clock := newTestQueue()
+		q.Enqueue(1, 1500)
+		clock.advance(time.Second)
+		q.Enqueue(2, 1500)
+		pairs := q.Match()
+		if len(pairs) != 1 {
+			t.Fatalf("expected 1 pair, got %d", len(pairs))
+		}
+		clock.advance(time.Second)
+		q.Enqueue(3, 1500)
+		q.Requeue(pairs[0].P1)
+
+		pairs = q.Match()
+		if len(pairs) != 1 || pairs[0].P1.UserID != 1 {
+			t.Fatalf("expected requeued user 1 to keep priority, got %+v", pairs)
+		}
+	})
+}
+
+// TestQueueConcurrent hammers the queue from many goroutines and checks
+// that nobody is handed out twice or left queued after being matched.
+func TestQueueConcurrent(t *testing.T) {
+	q := NewQueue(Window{Initial: 1000, Max: 1000})
+	const users = 200
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		matched = make(map[int64]int)
+	)
+	collect := func(pairs []Pair) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range pairs {
+			matched[p.P1.UserID]++
+			matched[p.P2.UserID]++
+		}
+	}
+
+	for i := range int64(users) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Enqueue(i, 1500+i%100)
+			if i%3 == 0 {
+				q.Dequeue(i)
+			}
+			collect(q.Match())
+		}()
+	}
+	wg.Wait()
+	collect(q.Match())
+
+	for id, n := range matched {
+		if n != 1 {
+			t.Errorf("user %d matched %d times", id, n)
+		}
+		if q.Contains(id) {
+			t.Errorf("user %d is matched and still queued", id)
+		}
+	}
+	if q.Len() > 1 {
+		t.Errorf("expected at most one user left unmatched, got %d", q.Len())
+	}
+}
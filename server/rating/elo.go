@@ -0,0 +1,35 @@
+// Package rating implements the Elo rating system used for the
+// leaderboard.
+package rating
+
+import "math"
+
+const (
+	// Initial is the rating of a new player.
+	Initial = 1500
+	// K is the largest change a single match can make.
+	K = 32
+)
+
+// Outcome is the score of player A in a match: 1 for a win, 0.5 for a
+// draw and 0 for a loss.
+type Outcome float64
+
+const (
+	Loss Outcome = 0
+	Draw Outcome = 0.5
+	Win  Outcome = 1
+)
+
+// Expected returns the probability that a player rated a beats a player
+// rated b.
+func Expected(a, b int64) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
+
+// Update returns the new ratings of players A and B after a match that A
+// scored outcome in. Points won by one player are lost by the other.
+func Update(a, b int64, outcome Outcome) (int64, int64) {
+	delta := int64(math.Round(K * (float64(outcome) - Expected(a, b))))
+	return a + delta, b - delta
+}
@@ -0,0 +1,45 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpected(t *testing.T) {
+	if got := Expected(1500, 1500); got != 0.5 {
+		t.Errorf("expected 0.5 for equal ratings, got %v", got)
+	}
+	if got := Expected(1900, 1500); math.Abs(got-0.909) > 0.001 {
+		t.Errorf("expected about 0.909 for a 400 point gap, got %v", got)
+	}
+	if sum := Expected(1600, 1400) + Expected(1400, 1600); math.Abs(sum-1) > 1e-9 {
+		t.Errorf("expected probabilities to sum to 1, got %v", sum)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    int64
+		outcome Outcome
+		wantA   int64
+		wantB   int64
+	}{
+		{"EvenWin", 1500, 1500, Win, 1516, 1484},
+		{"EvenDraw", 1500, 1500, Draw, 1500, 1500},
+		{"EvenLoss", 1500, 1500, Loss, 1484, 1516},
+		{"Upset", 1400, 1800, Win, 1429, 1771},
+		{"Expected", 1800, 1400, Win, 1803, 1397},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Update(tt.a, tt.b, tt.outcome)
+			if a != tt.wantA || b != tt.wantB {
+				t.Errorf("expected %d/%d, got %d/%d", tt.wantA, tt.wantB, a, b)
+			}
+			if a+b != tt.a+tt.b {
+				t.Errorf("expected ratings to be zero-sum, got %d", a+b-tt.a-tt.b)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sodefrin/PP/server/bot"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/game"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+const (
+	// botMoveDelay paces the bot so that humans can follow its moves.
+	botMoveDelay = 150 * time.Millisecond
+	// botMaxSteps bounds the inputs spent steering one pair. A pair that
+	// cannot reach its target, say because a kick moved it, is dropped
+	// where it is.
+	botMaxSteps = 8
+	// botJoinTimeout is how long a bot waits for its opponent to connect
+	// before it gives up on the room.
+	botJoinTimeout = 2 * time.Minute
+)
+
+// botSeat is a bot to seat in a room: the seat, the user it plays as and
+// how well it plays.
+type botSeat struct {
+	player game.Player
+	user   db.User
+	level  bot.Level
+}
+
+// StartBot seats a bot playing as user in dbRoom. The bot is an
+// in-process client: it reads the same frames a human connection is sent
+// and answers with inputs until the match is over.
+func (h *Hub) StartBot(dbRoom db.Room, user db.User, level bot.Level) error {
+	player, ok := seatFor(dbRoom, user.ID)
+	if !ok {
+		return errNotSeated
+	}
+	seat := botSeat{player: player, user: user, level: level}
+	userIDs := [2]int64{dbRoom.P1ID.Int64, dbRoom.P2ID.Int64}
+	// A room stored with its bot level has the bot seated as it opens.
+	return h.withRoom(context.Background(), dbRoom, func(room *Room) {
+		if !room.seated(player) {
+			h.seatBot(room, userIDs, seat)
+		}
+	})
+}
+
+// ResumeBots reopens the bot rooms a previous run left open, say across a
+// restart, so that their bots take their seats again. A bot whose
+// opponent does not come gives up on the room as usual. Rooms that cannot
+// be reopened are logged and skipped.
+func (h *Hub) ResumeBots(ctx context.Context) error {
+	rooms, err := h.queries.ListBotRooms(ctx)
+	if err != nil {
+		return err
+	}
+	for _, dbRoom := range rooms {
+		if err := h.withRoom(ctx, dbRoom, func(*Room) {}); err != nil {
+			slog.ErrorContext(ctx, "Failed to resume bot room", "room_id", dbRoom.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// storedBot returns the bot recorded for dbRoom, which plays player 2, or
+// nil for a room between two people.
+func (h *Hub) storedBot(ctx context.Context, dbRoom db.Room) (*botSeat, error) {
+	if dbRoom.BotLevel == "" {
+		return nil, nil
+	}
+	level, err := bot.ParseLevel(dbRoom.BotLevel)
+	if err != nil {
+		return nil, fmt.Errorf("room %d: %w", dbRoom.ID, err)
+	}
+	user, err := h.queries.GetUser(ctx, dbRoom.P2ID.Int64)
+	if err != nil {
+		return nil, err
+	}
+	return &botSeat{player: game.Player2, user: user, level: level}, nil
+}
+
+// seatBot seats seat in room and starts the bot. Callers must hold h.mu.
+func (h *Hub) seatBot(room *Room, userIDs [2]int64, seat botSeat) {
+	c := newClient(nil, seat.user, "")
+	room.join(c, seat.player, userIDs, nil)
+	go h.runBot(c, seat.level)
+}
+
+func (h *Hub) runBot(c *Client, level bot.Level) {
+	defer h.leave(c)
+	room := c.room
+	defer time.AfterFunc(h.botJoinTimeout, func() { h.abandonBotRoom(room) }).Stop()
+
+	ctx := context.Background()
+	b := botPlayer{player: c.player, level: level}
+	for {
+		state, ok := h.nextBotState(c)
+		if !ok || state.Over {
+			return
+		}
+		action, ok, err := b.next(state)
+		if err != nil {
+			slog.ErrorContext(ctx, "Bot could not read state", "room_id", c.room.id, "error", err)
+			return
+		}
+		if !ok {
+			continue
+		}
+
+		select {
+		case <-c.quit:
+			return
+		case <-time.After(h.botDelay):
+		}
+		// A rejected input means the state was stale; the newer one is
+		// already on its way.
+		if err := c.room.input(ctx, c, 0, InputPayload{Action: action.String()}); err != nil {
+			slog.DebugContext(ctx, "Bot input rejected", "room_id", c.room.id, "error", err)
+		}
+	}
+}
+
+// abandonBotRoom gives up on room if the match against its bot never
+// started: the bot leaves and the room is finished so that nobody joins
+// it later.
+func (h *Hub) abandonBotRoom(room *Room) {
+	if !room.dropBots() {
+		return
+	}
+	ctx := context.Background()
+	if _, err := h.queries.UpdateRoomStatus(ctx, db.UpdateRoomStatusParams{
+		Status: lib.RoomStatusFinished,
+		ID:     room.id,
+	}); err != nil {
+		slog.ErrorContext(ctx, "Failed to finish abandoned bot room", "room_id", room.id, "error", err)
+	}
+}
+
+// nextBotState waits for a state frame and returns the latest one queued.
+// It reports false once the client has been stopped.
+func (h *Hub) nextBotState(c *Client) (StatePayload, bool) {
+	var state StatePayload
+	found := false
+	for {
+		var frame []byte
+		if found {
+			select {
+			case frame = <-c.send:
+			default:
+				return state, true
+			}
+		} else {
+			select {
+			case frame = <-c.send:
+			case <-c.quit:
+				return StatePayload{}, false
+			}
+		}
+
+		var env Envelope
+		if err := json.Unmarshal(frame, &env); err != nil || env.Type != TypeState {
+			continue
+		}
+		if err := json.Unmarshal(env.Payload, &state); err != nil {
+			continue
+		}
+		found = true
+	}
+}
+
+// botPlayer keeps the placement chosen for the pair being steered.
+type botPlayer struct {
+	player game.Player
+	level  bot.Level
+
+	// planFor identifies the pair target was planned for by turn count
+	// and moves left.
+	planFor [2]int
+	planned bool
+	target  bot.Placement
+	steps   int
+}
+
+// next returns the input to send for state, or false if it is not the
+// bot's move.
+func (b *botPlayer) next(state StatePayload) (game.Action, bool, error) {
+	if state.Turn != b.player.String() || int(b.player) >= len(state.Players) {
+		return 0, false, nil
+	}
+	ps := state.Players[b.player]
+	if ps.Piece == nil {
+		return 0, false, nil
+	}
+	board, piece, pairs, err := parsePlayerState(ps)
+	if err != nil {
+		return 0, false, err
+	}
+
+	key := [2]int{state.TurnCount, ps.MovesLeft}
+	if !b.planned || b.planFor != key {
+		b.target = bot.Plan(board, pairs, b.level.Depth())
+		b.planFor, b.planned, b.steps = key, true, 0
+	}
+	b.steps++
+	if b.steps >= botMaxSteps {
+		return game.ActionHardDrop, true, nil
+	}
+	return bot.Next(piece, b.target), true, nil
+}
+
+// parsePlayerState turns the state of a player back into game types: the
+// board, the piece being steered and the pairs to place, current first.
+func parsePlayerState(ps PlayerState) (*game.Board, game.Piece, []game.Pair, error) {
+	board := game.NewBoard()
+	for r, row := range ps.Board {
+		for c, name := range row {
+			color, err := game.ParseColor(name)
+			if err != nil {
+				return nil, game.Piece{}, nil, err
+			}
+			if !game.InBounds(r, c) {
+				return nil, game.Piece{}, nil, fmt.Errorf("cell %d,%d out of bounds", r, c)
+			}
+			board.Set(r, c, color)
+		}
+	}
+
+	pair, err := parsePair(ps.Piece.Main, ps.Piece.Sub)
+	if err != nil {
+		return nil, game.Piece{}, nil, err
+	}
+	piece := game.Piece{Pair: pair, Row: ps.Piece.Row, Col: ps.Piece.Col, Rot: game.Rotation(ps.Piece.Rotation)}
+
+	pairs := []game.Pair{pair}
+	for _, next := range ps.Next {
+		pair, err := parsePair(next.Main, next.Sub)
+		if err != nil {
+			return nil, game.Piece{}, nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	return board, piece, pairs, nil
+}
+
+func parsePair(main, sub string) (game.Pair, error) {
+	m, err := game.ParseColor(main)
+	if err != nil {
+		return game.Pair{}, err
+	}
+	s, err := game.ParseColor(sub)
+	if err != nil {
+		return game.Pair{}, err
+	}
+	return game.Pair{Main: m, Sub: s}, nil
+}
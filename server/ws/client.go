@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/game"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+	sendBuffer     = 64
+)
+
+// Client is one websocket connection. All writes go through its send
+// queue and are performed by writePump, since gorilla/websocket allows
+// only one concurrent writer.
+type Client struct {
+	conn      *websocket.Conn
+	user      db.User
+	sessionID string
+	send      chan []byte
+	quit      chan struct{}
+	quitOnce  sync.Once
+	decoder   Decoder
+
+	// closeCode and closeReason are written once by stop and read by
+	// writePump after quit is closed.
+	closeCode   int
+	closeReason string
+
+	// room, player and spectator are set by Room.join or Room.spectate
+	// and only read by the goroutine serving the connection.
+	room      *Room
+	player    game.Player
+	spectator bool
+}
+
+func newClient(conn *websocket.Conn, user db.User, sessionID string) *Client {
+	return &Client{
+		conn:      conn,
+		user:      user,
+		sessionID: sessionID,
+		send:      make(chan []byte, sendBuffer),
+		quit:      make(chan struct{}),
+	}
+}
+
+// enqueue queues a frame without blocking. A client too slow to keep up is
+// disconnected rather than stalling the room.
+func (c *Client) enqueue(frame []byte) {
+	select {
+	case c.send <- frame:
+	default:
+		c.stop(websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+// stop closes the connection with the given close code. Only the first
+// call has an effect.
+func (c *Client) stop(code int, reason string) {
+	c.quitOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.quit)
+	})
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	// Closing the connection unblocks the read loop once we stop writing.
+	defer func() { _ = c.conn.Close() }()
+
+	for {
+		select {
+		case frame := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.quit:
+			c.drain()
+			msg := websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+			_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+			return
+		}
+	}
+}
+
+// reply sends a frame to this client only. Replies are not part of the
+// room's numbered stream and carry seq 0.
+func (c *Client) reply(msg Message) {
+	c.notify(0, msg)
+}
+
+// notify is reply about roomID, for frames such as match_found that
+// refer to a room the client has not joined.
+func (c *Client) notify(roomID int64, msg Message) {
+	frame, err := Encode(msg.Type, 0, roomID, msg.Payload)
+	if err != nil {
+		slog.Error("Failed to encode websocket frame", "error", err)
+		return
+	}
+	c.enqueue(frame)
+}
+
+// drain flushes frames that were queued before the client was stopped.
+func (c *Client) drain() {
+	for {
+		select {
+		case frame := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package ws implements the game protocol spoken over /ws.
+//
+// Every frame is a JSON text message wrapped in an Envelope:
+//
+//	{"v": 1, "type": "input", "seq": 3, "room_id": 42, "payload": {...}}
+//
+// v is the protocol version (currently 1); frames with any other version
+// are rejected. seq numbers frames per direction: a client numbers its
+// frames 1, 2, 3, ... and the server rejects any frame that does not carry
+// the next number. room_id identifies the room the frame refers to.
+//
+// Server frames broadcast to a room are numbered by the room, so every
+// member sees the same sequence. The snapshot sent on joining carries the
+// room's current seq; errors, match_found and clock are not part of the
+// room's stream and carry seq 0.
+//
+// Client to server:
+//
+//	join_room  {}                       take your seat in the room given by
+//	                                    room_id, as recorded by /api/rooms
+//	           {"role": "spectator"}    watch the room instead; anyone may
+//	                                    watch a room that is not finished
+//	           {"last_seq": 41}         come back after a dropped connection
+//	                                    and get what was missed since seq 41
+//	input      {"action": "left"}       left, right, rotate_cw, rotate_ccw,
+//	                                    drop (one row) or hard_drop
+//
+// Server to client:
+//
+//	state        full snapshot of both boards, pieces, next and next-next
+//	             pairs, move budgets, pending nuisance and scores
+//	chain        one step of a chain: player, chain, score, cleared, garbage
+//	nuisance     how nuisance was settled at the end of a turn: generated,
+//	             offset, sent and dropped, plus what is pending per player
+//	turn_change  turn, turn_count and the move budget of both players
+//	game_over    winner and reason
+//	presence     a player lost or regained their connection; while away
+//	             their seat is held until forfeit_at
+//	clock        tick of the turn clock, unnumbered: player on turn,
+//	             remaining_ms and each player's run of timeouts
+//	match_found  matchmaking put you in room_id: your player and the
+//	             opponent's user id; send join_room to take the seat
+//	error        code, message and the seq of the offending frame
+//
+// The match starts once both players of the room are connected at the
+// same time; until then joining players are sent the starting state and
+// inputs are answered with not_started.
+//
+// When a player's connection drops mid-match their seat is held for the
+// hub's reconnect grace period (WS_RECONNECT_GRACE) and the opponent is
+// sent presence. Joining again with last_seq resends the room frames
+// missed since then under their original seq, or a snapshot if they are
+// no longer buffered; without last_seq a snapshot is sent. A new
+// connection takes the seat over from an old one, which is closed. A
+// player who does not come back in time forfeits, ending the match with
+// reason "forfeit".
+//
+// With time limits configured (WS_TURN_TIME_LIMIT, WS_MOVE_TIME_LIMIT) the
+// turn clock starts once both players are seated and ticks every second.
+// A player who runs out of time has their pair hard dropped where it is;
+// at the turn limit the rest of the turn is dropped too. These drops are
+// recorded like any other input. WS_MAX_TIMEOUTS timeouts in a row lose
+// the match with reason "timeout".
+//
+// A room created by POST /api/rooms with "opponent": "bot" has a bot in
+// the player 2 seat. The bot is an in-process client: it is sent the same
+// frames as a human player, plans a placement from each state by
+// searching over the server's board engine (one, two or three pairs deep
+// for easy, normal and hard) and answers with ordinary inputs. Matches
+// against a bot are recorded but not rated. If player 1 leaves, or does
+// not connect within two minutes, before the match starts, the bot leaves
+// and the room is finished. The bot's level is stored with the room, so
+// when the room is reopened, say after a restart, the bot takes its seat
+// again.
+//
+// Spectators receive the same numbered frames as the players, held back by
+// the hub's spectator delay (WS_SPECTATOR_DELAY) so they cannot be used to
+// watch the opponent live. Clock ticks are held back by the same delay.
+// A spectator joining late starts from the state as it was that long ago.
+// Inputs from spectators are answered with read_only.
+//
+// Pairs come from a seeded generator owned by the server. The seed is kept
+// in the rooms table and never sent to clients, which only see the preview.
+// Every accepted input is stored in match_events before it is applied,
+// and an input that cannot be stored is refused with unavailable. Once
+// the match is over, seed and inputs are published by
+// /api/matches/{id}/replay. A room whose match was under way when the
+// server let it go, say across a restart, replays its stored inputs when
+// reopened and goes on from there.
+//
+// The connection must be opened with a valid session cookie. When the
+// session expires or is revoked mid-game the server closes the socket with
+// close code 1008 (policy violation) and reason "session expired".
+//
+// Error codes are listed as the Code* constants. A malformed or
+// out-of-order frame is answered with an error and otherwise ignored; the
+// connection stays open.
+package ws
@@ -0,0 +1,407 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/game"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+const (
+	sessionCheckInterval = 30 * time.Second
+	clockTick            = time.Second
+)
+
+var errNotSeated = errors.New("user is not a player in this room")
+
+// Options tunes a Hub. The zero value is valid.
+type Options struct {
+	// SpectatorDelay holds back what spectators see, so that a player
+	// cannot follow the opponent's moves through a spectator's screen.
+	SpectatorDelay time.Duration
+	// ReconnectGrace is how long the seat of a player whose connection
+	// dropped mid-match is held before they forfeit. Zero forfeits at once.
+	ReconnectGrace time.Duration
+	// TimeLimits bound turns and moves. Players are told the time left
+	// every second and their pair is hard dropped when it runs out.
+	TimeLimits game.TimeLimits
+}
+
+// Hub groups connections by room and by user. Lock order is Hub.mu, then
+// Room.mu.
+type Hub struct {
+	conn    *sql.DB
+	queries *db.Queries
+	policy  lib.SessionPolicy
+	opts    Options
+
+	sessionCheckInterval time.Duration
+	clockTick            time.Duration
+	botDelay             time.Duration
+	botJoinTimeout       time.Duration
+
+	mu      sync.Mutex
+	rooms   map[int64]*Room
+	clients map[int64]map[*Client]struct{}
+	// dropped counts the rooms let go, so that a room loaded without mu
+	// can be told to be stale.
+	dropped uint64
+}
+
+func NewHub(conn *sql.DB, queries *db.Queries, policy lib.SessionPolicy, opts Options) *Hub {
+	return &Hub{
+		conn:                 conn,
+		queries:              queries,
+		policy:               policy,
+		opts:                 opts,
+		sessionCheckInterval: sessionCheckInterval,
+		clockTick:            clockTick,
+		botDelay:             botMoveDelay,
+		botJoinTimeout:       botJoinTimeout,
+		rooms:                make(map[int64]*Room),
+		clients:              make(map[int64]map[*Client]struct{}),
+	}
+}
+
+// Serve runs the connection of an authenticated user until it is closed.
+// It owns conn and closes it before returning.
+func (h *Hub) Serve(ctx context.Context, conn *websocket.Conn, user db.User, sessionID string) error {
+	c := newClient(conn, user, sessionID)
+	h.register(c)
+	done := make(chan struct{})
+	go func() {
+		c.writePump()
+		close(done)
+	}()
+	go h.watchSession(ctx, c)
+
+	err := h.readPump(ctx, c)
+	h.leave(c)
+	h.unregister(c)
+	c.stop(websocket.CloseNormalClosure, "")
+	<-done
+	return err
+}
+
+func (h *Hub) register(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	set, ok := h.clients[c.user.ID]
+	if !ok {
+		set = make(map[*Client]struct{})
+		h.clients[c.user.ID] = set
+	}
+	set[c] = struct{}{}
+}
+
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	set := h.clients[c.user.ID]
+	delete(set, c)
+	if len(set) == 0 {
+		delete(h.clients, c.user.ID)
+	}
+}
+
+// NotifyMatch sends match_found to every connection of both players of a
+// room created by matchmaking. Players who are not connected find the
+// room through /api/rooms instead.
+func (h *Hub) NotifyMatch(room db.Room) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ids := [2]int64{room.P1ID.Int64, room.P2ID.Int64}
+	for p, id := range ids {
+		msg := Message{TypeMatchFound, MatchFoundPayload{
+			Player:     game.Player(p).String(),
+			OpponentID: ids[1-p],
+		}}
+		for c := range h.clients[id] {
+			c.notify(room.ID, msg)
+		}
+	}
+}
+
+// watchSession closes the connection once its session expires or is
+// revoked. An open connection counts as activity, so sliding sessions are
+// renewed while a match is in progress.
+func (h *Hub) watchSession(ctx context.Context, c *Client) {
+	ticker := time.NewTicker(h.sessionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.quit:
+			return
+		case <-ticker.C:
+			session, err := h.queries.GetSession(ctx, c.sessionID)
+			if err != nil && err != sql.ErrNoRows {
+				slog.ErrorContext(ctx, "GetSession error", "error", err)
+				continue
+			}
+			now := time.Now()
+			if err == sql.ErrNoRows || now.After(session.ExpiresAt) {
+				slog.InfoContext(ctx, "Closing websocket for expired session")
+				c.stop(websocket.ClosePolicyViolation, "session expired")
+				return
+			}
+			if _, _, err := lib.RenewSession(ctx, h.queries, h.policy, session, now); err != nil {
+				slog.ErrorContext(ctx, "RenewSession error", "error", err)
+			}
+		}
+	}
+}
+
+func (h *Hub) readPump(ctx context.Context, c *Client) error {
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			// Dropped connections are routine; only odd close codes are errors.
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway,
+				websocket.CloseNoStatusReceived, websocket.CloseAbnormalClosure) {
+				return err
+			}
+			return nil
+		}
+
+		if err := h.handle(ctx, c, data); err != nil {
+			var perr *ProtocolError
+			if !errors.As(err, &perr) {
+				return err
+			}
+			slog.InfoContext(ctx, "Rejected websocket frame", "code", perr.Code, "error", perr.Message)
+			c.reply(Message{TypeError, perr.Payload()})
+		}
+	}
+}
+
+func (h *Hub) handle(ctx context.Context, c *Client, data []byte) error {
+	env, err := c.decoder.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	switch env.Type {
+	case TypeJoinRoom:
+		var payload JoinRoomPayload
+		if err := DecodePayload(env, &payload); err != nil {
+			return err
+		}
+		switch payload.Role {
+		case "", RolePlayer:
+			return h.join(ctx, c, env, payload)
+		case RoleSpectator:
+			return h.spectate(ctx, c, env)
+		}
+		return &ProtocolError{Code: CodeInvalidPayload, Message: "unknown role " + payload.Role, RefSeq: env.Seq}
+
+	case TypeInput:
+		if c.room == nil || c.room.id != env.RoomID {
+			return &ProtocolError{Code: CodeNotJoined, Message: "join the room first", RefSeq: env.Seq}
+		}
+		if c.spectator {
+			return &ProtocolError{Code: CodeReadOnly, Message: "spectators cannot send input", RefSeq: env.Seq}
+		}
+		var payload InputPayload
+		if err := DecodePayload(env, &payload); err != nil {
+			return err
+		}
+		slog.DebugContext(ctx, "Websocket input", "room_id", env.RoomID, "action", payload.Action)
+		return c.room.input(ctx, c, env.Seq, payload)
+	}
+	return nil
+}
+
+func (h *Hub) join(ctx context.Context, c *Client, env Envelope, payload JoinRoomPayload) error {
+	dbRoom, err := h.openRoom(ctx, c, env)
+	if err != nil {
+		return err
+	}
+	player, ok := seatFor(dbRoom, c.user.ID)
+	if !ok {
+		return &ProtocolError{Code: CodeForbidden, Message: "not a player in this room", RefSeq: env.Seq}
+	}
+
+	userIDs := [2]int64{dbRoom.P1ID.Int64, dbRoom.P2ID.Int64}
+	return h.withRoom(ctx, dbRoom, func(room *Room) {
+		room.join(c, player, userIDs, payload.LastSeq)
+	})
+}
+
+// spectate adds c to the room as a read-only member. Anyone signed in may
+// watch a room that is not finished.
+func (h *Hub) spectate(ctx context.Context, c *Client, env Envelope) error {
+	dbRoom, err := h.openRoom(ctx, c, env)
+	if err != nil {
+		return err
+	}
+
+	return h.withRoom(ctx, dbRoom, func(room *Room) {
+		room.spectate(c)
+	})
+}
+
+// openRoom loads the room env refers to and checks that c may enter it.
+func (h *Hub) openRoom(ctx context.Context, c *Client, env Envelope) (db.Room, error) {
+	if c.room != nil {
+		return db.Room{}, &ProtocolError{Code: CodeAlreadyJoined, Message: "already in a room", RefSeq: env.Seq}
+	}
+
+	dbRoom, err := h.queries.GetRoom(ctx, env.RoomID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.Room{}, &ProtocolError{Code: CodeRoomNotFound, Message: "room not found", RefSeq: env.Seq}
+		}
+		return db.Room{}, err
+	}
+	if dbRoom.Status == lib.RoomStatusFinished {
+		return db.Room{}, &ProtocolError{Code: CodeRoomClosed, Message: "room is finished", RefSeq: env.Seq}
+	}
+	return dbRoom, nil
+}
+
+// withRoom calls enter with h.mu held on the live room of dbRoom, opening
+// it if needed. Opening loads the room without h.mu, so that replaying a
+// long match does not hold up the rest of the hub. If a room was let go
+// in the meantime the load may be stale, and it is done again.
+func (h *Hub) withRoom(ctx context.Context, dbRoom db.Room, enter func(*Room)) error {
+	for {
+		h.mu.Lock()
+		if room, ok := h.rooms[dbRoom.ID]; ok {
+			enter(room)
+			h.mu.Unlock()
+			return nil
+		}
+		dropped := h.dropped
+		h.mu.Unlock()
+
+		room, seat, err := h.loadRoom(ctx, dbRoom)
+		if err != nil {
+			return err
+		}
+
+		h.mu.Lock()
+		if _, ok := h.rooms[dbRoom.ID]; !ok && h.dropped == dropped {
+			h.rooms[dbRoom.ID] = room
+			if seat != nil {
+				h.seatBot(room, [2]int64{dbRoom.P1ID.Int64, dbRoom.P2ID.Int64}, *seat)
+			}
+			enter(room)
+			h.mu.Unlock()
+			return nil
+		}
+		h.mu.Unlock()
+	}
+}
+
+// loadRoom opens dbRoom and returns it with the bot to seat in it, if it
+// has one. A room with inputs stored, whose live room was let go, say by
+// a restart, picks its match up from them.
+func (h *Hub) loadRoom(ctx context.Context, dbRoom db.Room) (*Room, *botSeat, error) {
+	events, err := h.queries.ListMatchEvents(ctx, dbRoom.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	seat, err := h.storedBot(ctx, dbRoom)
+	if err != nil {
+		return nil, nil, err
+	}
+	room := newRoom(dbRoom, h.conn, h.queries, h.opts, h.clockTick, h.release)
+	if err := room.resume(events); err != nil {
+		return nil, nil, err
+	}
+	return room, seat, nil
+}
+
+// dropRoom lets room go. Callers must hold h.mu.
+func (h *Hub) dropRoom(room *Room) {
+	if h.rooms[room.id] == room {
+		delete(h.rooms, room.id)
+		h.dropped++
+	}
+}
+
+// Forfeit ends the match in dbRoom with userID losing, for a player who
+// leaves the room through the API rather than by disconnecting. A match in
+// progress is recorded and finishes the room; before the start or after
+// the end there is nothing to forfeit and nothing happens.
+func (h *Hub) Forfeit(ctx context.Context, dbRoom db.Room, userID int64) error {
+	player, ok := seatFor(dbRoom, userID)
+	if !ok {
+		return errNotSeated
+	}
+
+	return h.withRoom(ctx, dbRoom, func(room *Room) {
+		room.resign(player)
+		// The room may have been opened just to be forfeited.
+		if room.empty() {
+			h.dropRoom(room)
+		}
+	})
+}
+
+// release drops room once it is empty.
+func (h *Hub) release(room *Room) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if room.empty() {
+		h.dropRoom(room)
+	}
+}
+
+// Spectators returns how many spectators are watching roomID.
+func (h *Hub) Spectators(roomID int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[roomID]
+	if !ok {
+		return 0
+	}
+	return room.spectatorCount()
+}
+
+// seatFor returns the seat of userID in the room as recorded in the rooms
+// table.
+func seatFor(room db.Room, userID int64) (game.Player, bool) {
+	switch {
+	case room.P1ID.Valid && room.P1ID.Int64 == userID:
+		return game.Player1, true
+	case room.P2ID.Valid && room.P2ID.Int64 == userID:
+		return game.Player2, true
+	}
+	return 0, false
+}
+
+func (h *Hub) leave(c *Client) {
+	if c.room == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room := c.room
+	if room.leave(c) {
+		h.dropRoom(room)
+	}
+	c.room = nil
+}
@@ -0,0 +1,1184 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/sodefrin/PP/server/bot"
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/game"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+func newTestServer(t *testing.T, hub *Hub) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	handler := lib.AuthMiddleware(testQueries, lib.DefaultSessionPolicy())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := lib.GetUserContext(r.Context())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		session, err := lib.GetSessionContext(r.Context())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = hub.Serve(r.Context(), conn, user, session.ID)
+	}))
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+type testUser struct {
+	db.User
+	sessionID string
+}
+
+func createTestUser(t *testing.T, name string) testUser {
+	t.Helper()
+	ctx := context.Background()
+	user, err := testQueries.CreateUser(ctx, db.CreateUserParams{Name: name, PasswordHash: "x"})
+	if err != nil {
+		t.Fatalf("CreateUser error: %v", err)
+	}
+	session, err := testQueries.CreateSession(ctx, db.CreateSessionParams{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		ExpiresAt:  time.Now().Add(time.Hour),
+		CreatedAt:  time.Now(),
+		LastSeenAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateSession error: %v", err)
+	}
+	return testUser{User: user, sessionID: session.ID}
+}
+
+const testSeed = 1234
+
+// createTestRoom creates a playing room for p1 and p2.
+func createTestRoom(t *testing.T, p1, p2 testUser) int64 {
+	t.Helper()
+	ctx := context.Background()
+	room, err := testQueries.CreateRoom(ctx, db.CreateRoomParams{
+		P1ID:        sql.NullInt64{Int64: p1.ID, Valid: true},
+		Status:      lib.RoomStatusWaiting,
+		Seed:        testSeed,
+		SharedQueue: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateRoom error: %v", err)
+	}
+	room, err = testQueries.JoinRoom(ctx, db.JoinRoomParams{
+		P2ID: sql.NullInt64{Int64: p2.ID, Valid: true},
+		ID:   room.ID,
+	})
+	if err != nil {
+		t.Fatalf("JoinRoom error: %v", err)
+	}
+	return room.ID
+}
+
+type testConn struct {
+	t    *testing.T
+	conn *websocket.Conn
+	seq  uint64
+}
+
+func dial(t *testing.T, url string, user testUser) *testConn {
+	t.Helper()
+	header := http.Header{}
+	header.Set("Cookie", "session_id="+user.sessionID)
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return &testConn{t: t, conn: conn}
+}
+
+func (c *testConn) send(msgType string, roomID int64, payload string) {
+	c.t.Helper()
+	c.seq++
+	env := Envelope{Version: Version, Type: msgType, Seq: c.seq, RoomID: roomID}
+	if payload != "" {
+		env.Payload = json.RawMessage(payload)
+	}
+	if err := c.conn.WriteJSON(env); err != nil {
+		c.t.Fatalf("WriteJSON error: %v", err)
+	}
+}
+
+func (c *testConn) read() Envelope {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var env Envelope
+	if err := c.conn.ReadJSON(&env); err != nil {
+		c.t.Fatalf("ReadJSON error: %v", err)
+	}
+	return env
+}
+
+// readUntil skips frames until one of msgType arrives.
+func (c *testConn) readUntil(msgType string) Envelope {
+	c.t.Helper()
+	for {
+		if env := c.read(); env.Type == msgType {
+			return env
+		}
+	}
+}
+
+func (c *testConn) expectError(code string) {
+	c.t.Helper()
+	env := c.readUntil(TypeError)
+	var payload ErrorPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		c.t.Fatalf("failed to decode error: %v", err)
+	}
+	if payload.Code != code {
+		c.t.Errorf("expected error %s, got %+v", code, payload)
+	}
+	if env.Seq != 0 {
+		c.t.Errorf("expected error frame with seq 0, got %d", env.Seq)
+	}
+}
+
+func (h *Hub) roomCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.rooms)
+}
+
+func TestHubBroadcastsToBothPlayers(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "hub-alice")
+	bob := createTestUser(t, "hub-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	if env := p1.read(); env.Type != TypeState || env.Seq != 0 {
+		t.Fatalf("expected initial state at seq 0, got %+v", env)
+	}
+
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+
+	p1.send(TypeInput, roomID, `{"action":"left"}`)
+	e1, e2 := p1.read(), p2.read()
+	if e1.Type != TypeState || e2.Type != TypeState {
+		t.Fatalf("expected state broadcast, got %s and %s", e1.Type, e2.Type)
+	}
+	if e1.Seq != 1 || e2.Seq != 1 {
+		t.Errorf("expected both to see seq 1, got %d and %d", e1.Seq, e2.Seq)
+	}
+
+	t.Run("OutOfTurn", func(t *testing.T) {
+		p2.send(TypeInput, roomID, `{"action":"left"}`)
+		p2.expectError(CodeNotYourTurn)
+	})
+
+	t.Run("WrongRoom", func(t *testing.T) {
+		p1.send(TypeInput, roomID+1, `{"action":"left"}`)
+		p1.expectError(CodeNotJoined)
+	})
+}
+
+func TestHubJoinValidation(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "join-alice")
+	bob := createTestUser(t, "join-bob")
+	eve := createTestUser(t, "join-eve")
+	roomID := createTestRoom(t, alice, bob)
+
+	t.Run("NotAPlayer", func(t *testing.T) {
+		c := dial(t, url, eve)
+		c.send(TypeJoinRoom, roomID, "")
+		c.expectError(CodeForbidden)
+	})
+
+	t.Run("RoomNotFound", func(t *testing.T) {
+		c := dial(t, url, alice)
+		c.send(TypeJoinRoom, 999999, "")
+		c.expectError(CodeRoomNotFound)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			t.Fatal("expected dial to fail")
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %v", resp)
+		}
+	})
+}
+
+func TestHubWaitsForBothPlayers(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{ReconnectGrace: time.Minute})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "wait-alice")
+	bob := createTestUser(t, "wait-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p1.send(TypeInput, roomID, `{"action":"hard_drop"}`)
+	p1.expectError(CodeNotStarted)
+
+	// Leaving before the start holds no seat, so the room goes.
+	_ = p1.conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.roomCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the unstarted room to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+	p1 = dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p1.send(TypeInput, roomID, `{"action":"hard_drop"}`)
+	if env := p1.read(); env.Type != TypeState || env.Seq != 1 {
+		t.Fatalf("expected the first move at seq 1, got %+v", env)
+	}
+
+	events, err := testQueries.ListMatchEvents(context.Background(), roomID)
+	if err != nil {
+		t.Fatalf("ListMatchEvents error: %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("expected only the move after the start to be stored, got %d", len(events))
+	}
+}
+
+func TestHubRefusesInputItCannotStore(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "unstored-alice")
+	bob := createTestUser(t, "unstored-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+
+	// Taking the next seq makes storing the input fail.
+	ctx := context.Background()
+	if err := testQueries.CreateMatchEvent(ctx, db.CreateMatchEventParams{
+		RoomID:    roomID,
+		Seq:       1,
+		Player:    int64(game.Player2),
+		Action:    game.ActionLeft.String(),
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("CreateMatchEvent error: %v", err)
+	}
+	p1.send(TypeInput, roomID, `{"action":"hard_drop"}`)
+	p1.expectError(CodeUnavailable)
+
+	hub.mu.Lock()
+	room := hub.rooms[roomID]
+	hub.mu.Unlock()
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if room.inputs != 0 || room.seq != 0 || room.match.MovesLeft(game.Player1) != game.TurnMoves {
+		t.Errorf("expected the input not to be applied, got %d inputs at seq %d", room.inputs, room.seq)
+	}
+}
+
+func TestHubStateIncludesSeededPreview(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "preview-alice")
+	bob := createTestUser(t, "preview-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	var state StatePayload
+	if err := json.Unmarshal(p1.readUntil(TypeState).Payload, &state); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+
+	reference := game.NewGenerator(testSeed, true)
+	current := reference.Next(game.Player1)
+	want := reference.Peek(game.Player1, game.PreviewLen)
+
+	p1State := state.Players[game.Player1]
+	if p1State.Piece == nil || p1State.Piece.Main != current.Main.String() || p1State.Piece.Sub != current.Sub.String() {
+		t.Errorf("expected current pair %v, got %+v", current, p1State.Piece)
+	}
+	if len(p1State.Next) != game.PreviewLen {
+		t.Fatalf("expected %d preview pairs, got %d", game.PreviewLen, len(p1State.Next))
+	}
+	for i, pair := range want {
+		if got := p1State.Next[i]; got.Main != pair.Main.String() || got.Sub != pair.Sub.String() {
+			t.Errorf("preview %d: expected %v, got %+v", i, pair, got)
+		}
+	}
+}
+
+// playToEnd hard-drops pieces for whoever is on turn until the match is
+// over and returns the final state.
+func playToEnd(t *testing.T, roomID int64, players [2]*testConn) StatePayload {
+	t.Helper()
+	turn := game.Player1
+	for range 500 {
+		players[turn].send(TypeInput, roomID, `{"action":"hard_drop"}`)
+		var state StatePayload
+		for _, c := range players {
+			if err := json.Unmarshal(c.readUntil(TypeState).Payload, &state); err != nil {
+				t.Fatalf("failed to decode state: %v", err)
+			}
+		}
+		if state.Over {
+			return state
+		}
+		if state.Turn == game.Player2.String() {
+			turn = game.Player2
+		} else {
+			turn = game.Player1
+		}
+	}
+	t.Fatal("match did not end")
+	return StatePayload{}
+}
+
+func TestHubRecordsFinishedMatch(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "record-alice")
+	bob := createTestUser(t, "record-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+
+	state := playToEnd(t, roomID, [2]*testConn{p1, p2})
+
+	ctx := context.Background()
+	matches, err := testQueries.ListMatchesByUser(ctx, db.ListMatchesByUserParams{
+		P1ID: alice.ID, P2ID: alice.ID, Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("ListMatchesByUser error: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 recorded match, got %d", len(matches))
+	}
+	m := matches[0]
+	winner := alice.ID
+	if state.Winner == game.Player2.String() {
+		winner = bob.ID
+	}
+	if m.RoomID != roomID || m.P1ID != alice.ID || m.P2ID != bob.ID || m.WinnerID.Int64 != winner {
+		t.Errorf("unexpected match: %+v", m)
+	}
+	if m.P1Score != int64(state.Players[game.Player1].Score) || m.Seed != testSeed || m.EndReason != lib.MatchEndTopOut {
+		t.Errorf("unexpected match: %+v", m)
+	}
+
+	room, err := testQueries.GetRoom(ctx, roomID)
+	if err != nil {
+		t.Fatalf("GetRoom error: %v", err)
+	}
+	if room.Status != lib.RoomStatusFinished {
+		t.Errorf("expected room to be finished, got %s", room.Status)
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		events, err := testQueries.ListMatchEvents(ctx, roomID)
+		if err != nil {
+			t.Fatalf("ListMatchEvents error: %v", err)
+		}
+		inputs := make([]game.Input, 0, len(events))
+		for i, e := range events {
+			if e.Seq != int64(i+1) {
+				t.Fatalf("expected event seq %d, got %d", i+1, e.Seq)
+			}
+			action, err := game.ParseAction(e.Action)
+			if err != nil {
+				t.Fatalf("ParseAction error: %v", err)
+			}
+			inputs = append(inputs, game.Input{Player: game.Player(e.Player), Turn: int(e.Turn), Action: action})
+		}
+
+		replayed, _, err := game.Replay(uint64(m.Seed), room.SharedQueue, inputs)
+		if err != nil {
+			t.Fatalf("Replay error: %v", err)
+		}
+		if !replayed.Over() || int64(replayed.Score(game.Player1)) != m.P1Score || int64(replayed.Score(game.Player2)) != m.P2Score {
+			t.Errorf("replay ended at %d-%d (over %v), expected %d-%d",
+				replayed.Score(game.Player1), replayed.Score(game.Player2), replayed.Over(), m.P1Score, m.P2Score)
+		}
+	})
+}
+
+func TestHubResumesStoredMatch(t *testing.T) {
+	alice := createTestUser(t, "resume-db-alice")
+	bob := createTestUser(t, "resume-db-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	before := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{ReconnectGrace: time.Minute})
+	url := newTestServer(t, before)
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+	p1.send(TypeInput, roomID, `{"action":"hard_drop"}`)
+	var placed StatePayload
+	if err := json.Unmarshal(p1.readUntil(TypeState).Payload, &placed); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+
+	// A new hub, as after a restart, knows the room only from the database.
+	after := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{ReconnectGrace: time.Minute})
+	url = newTestServer(t, after)
+	p1 = dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, `{"last_seq":1}`)
+	var resumed StatePayload
+	if err := json.Unmarshal(p1.readUntil(TypeState).Payload, &resumed); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	if !reflect.DeepEqual(resumed.Players[game.Player1].Board, placed.Players[game.Player1].Board) {
+		t.Errorf("expected the board as it was, got %v", resumed.Players[game.Player1].Board)
+	}
+	if away := decodePresence(t, p1.read()); away.Player != game.Player2.String() || away.ForfeitAt == nil {
+		t.Errorf("expected p2's seat to be held, got %+v", away)
+	}
+
+	p2 = dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+	p1.send(TypeInput, roomID, `{"action":"hard_drop"}`)
+	p1.readUntil(TypeState)
+
+	events, err := testQueries.ListMatchEvents(context.Background(), roomID)
+	if err != nil {
+		t.Fatalf("ListMatchEvents error: %v", err)
+	}
+	if len(events) != 2 || events[1].Seq != 2 {
+		t.Fatalf("expected the new input stored as seq 2, got %+v", events)
+	}
+}
+
+func TestHubNotifyMatch(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "notify-alice")
+	bob := createTestUser(t, "notify-bob")
+	roomID := createTestRoom(t, alice, bob)
+	room, err := testQueries.GetRoom(context.Background(), roomID)
+	if err != nil {
+		t.Fatalf("GetRoom error: %v", err)
+	}
+
+	conns := [2]*testConn{dial(t, url, alice), dial(t, url, bob)}
+	for _, c := range conns {
+		// A round trip makes sure the connection is registered.
+		c.send(TypeInput, roomID, `{"action":"left"}`)
+		c.expectError(CodeNotJoined)
+	}
+
+	hub.NotifyMatch(room)
+	for p, c := range conns {
+		env := c.read()
+		if env.Type != TypeMatchFound || env.RoomID != roomID || env.Seq != 0 {
+			t.Fatalf("expected match_found for room %d at seq 0, got %+v", roomID, env)
+		}
+		var payload MatchFoundPayload
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			t.Fatalf("Unmarshal error: %v", err)
+		}
+		opponent := [2]int64{bob.ID, alice.ID}[p]
+		if payload.Player != game.Player(p).String() || payload.OpponentID != opponent {
+			t.Errorf("expected %s against %d, got %+v", game.Player(p), opponent, payload)
+		}
+	}
+
+	// The seat can then be taken as usual.
+	conns[0].send(TypeJoinRoom, roomID, "")
+	conns[0].readUntil(TypeState)
+}
+
+func TestHubClosesExpiredSession(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	hub.sessionCheckInterval = 20 * time.Millisecond
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "expire-alice")
+
+	c := dial(t, url, alice)
+	if err := testQueries.DeleteSession(context.Background(), alice.sessionID); err != nil {
+		t.Fatalf("DeleteSession error: %v", err)
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := c.conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("expected close 1008, got %v", err)
+	}
+}
+
+func TestHubCleansUpOnDisconnect(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "cleanup-alice")
+	bob := createTestUser(t, "cleanup-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.read()
+	if hub.roomCount() != 1 {
+		t.Fatalf("expected 1 room, got %d", hub.roomCount())
+	}
+
+	_ = p1.conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.roomCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected room to be removed after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHubSpectator(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "watch-alice")
+	bob := createTestUser(t, "watch-bob")
+	carol := createTestUser(t, "watch-carol")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+
+	watcher := dial(t, url, carol)
+	watcher.send(TypeJoinRoom, roomID, `{"role":"spectator"}`)
+	if env := watcher.read(); env.Type != TypeState || env.Seq != 0 {
+		t.Fatalf("expected initial state at seq 0, got %+v", env)
+	}
+	if n := hub.Spectators(roomID); n != 1 {
+		t.Errorf("expected 1 spectator, got %d", n)
+	}
+
+	p1.send(TypeInput, roomID, `{"action":"left"}`)
+	if env := watcher.read(); env.Type != TypeState || env.Seq != 1 {
+		t.Errorf("expected spectator to see state at seq 1, got %+v", env)
+	}
+
+	t.Run("InputRejected", func(t *testing.T) {
+		watcher.send(TypeInput, roomID, `{"action":"left"}`)
+		watcher.expectError(CodeReadOnly)
+	})
+
+	t.Run("UnknownRole", func(t *testing.T) {
+		c := dial(t, url, carol)
+		c.send(TypeJoinRoom, roomID, `{"role":"referee"}`)
+		c.expectError(CodeInvalidPayload)
+	})
+
+	t.Run("Leave", func(t *testing.T) {
+		_ = watcher.conn.Close()
+		deadline := time.Now().Add(2 * time.Second)
+		for hub.Spectators(roomID) != 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected spectator to be removed after disconnect")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestHubSpectatorDelay(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{SpectatorDelay: delay})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "delay-alice")
+	bob := createTestUser(t, "delay-bob")
+	carol := createTestUser(t, "delay-carol")
+	dave := createTestUser(t, "delay-dave")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+	watcher := dial(t, url, carol)
+	watcher.send(TypeJoinRoom, roomID, `{"role":"spectator"}`)
+	watcher.readUntil(TypeState)
+
+	sent := time.Now()
+	p1.send(TypeInput, roomID, `{"action":"left"}`)
+	p1.read()
+
+	// A spectator arriving now starts from the delayed view.
+	late := dial(t, url, dave)
+	late.send(TypeJoinRoom, roomID, `{"role":"spectator"}`)
+	if env := late.read(); env.Seq != 0 {
+		t.Errorf("expected late spectator to start at seq 0, got %d", env.Seq)
+	}
+
+	env := watcher.read()
+	if env.Type != TypeState || env.Seq != 1 {
+		t.Fatalf("expected delayed state at seq 1, got %+v", env)
+	}
+	if elapsed := time.Since(sent); elapsed < delay {
+		t.Errorf("expected the broadcast to be held back %v, arrived after %v", delay, elapsed)
+	}
+	if env := late.read(); env.Seq != 1 {
+		t.Errorf("expected late spectator to catch up to seq 1, got %d", env.Seq)
+	}
+}
+
+func decodePresence(t *testing.T, env Envelope) PresencePayload {
+	t.Helper()
+	if env.Type != TypePresence {
+		t.Fatalf("expected presence, got %+v", env)
+	}
+	var payload PresencePayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	return payload
+}
+
+func TestHubReconnect(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{ReconnectGrace: 300 * time.Millisecond})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "resume-alice")
+	bob := createTestUser(t, "resume-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+	p1.send(TypeInput, roomID, `{"action":"left"}`)
+	if env := p1.read(); env.Seq != 1 {
+		t.Fatalf("expected seq 1, got %+v", env)
+	}
+	p2.read()
+
+	// Connections opened by subtests must outlive them.
+	parent := t
+
+	t.Run("Resume", func(t *testing.T) {
+		_ = p1.conn.Close()
+		away := decodePresence(t, p2.read())
+		if away.Player != game.Player1.String() || away.Connected || away.ForfeitAt == nil {
+			t.Errorf("expected p1 to be away with a deadline, got %+v", away)
+		}
+
+		p1 = dial(parent, url, alice)
+		p1.send(TypeJoinRoom, roomID, `{"last_seq":1}`)
+		missed := p1.read()
+		if missed.Seq != 2 || missed.Type != TypePresence {
+			t.Errorf("expected the missed presence at seq 2, got %+v", missed)
+		}
+		back := p1.read()
+		if back.Seq != 3 || !decodePresence(t, back).Connected {
+			t.Errorf("expected p1 back at seq 3, got %+v", back)
+		}
+		if env := p2.read(); env.Seq != 3 || !decodePresence(t, env).Connected {
+			t.Errorf("expected p2 to see p1 back at seq 3, got %+v", env)
+		}
+
+		// The match goes on where it was.
+		p1.send(TypeInput, roomID, `{"action":"left"}`)
+		if env := p1.read(); env.Type != TypeState || env.Seq != 4 {
+			t.Errorf("expected state at seq 4, got %+v", env)
+		}
+		p2.read()
+	})
+
+	t.Run("Takeover", func(t *testing.T) {
+		again := dial(parent, url, alice)
+		again.send(TypeJoinRoom, roomID, `{"last_seq":999}`)
+		if env := again.read(); env.Type != TypeState || env.Seq != 4 {
+			t.Errorf("expected a snapshot at seq 4, got %+v", env)
+		}
+
+		_ = p1.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := p1.conn.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("expected the old connection to be closed, got %v", err)
+		}
+		p1 = again
+	})
+
+	t.Run("Forfeit", func(t *testing.T) {
+		_ = p1.conn.Close()
+		decodePresence(t, p2.read())
+
+		over := p2.readUntil(TypeGameOver)
+		var payload GameOverPayload
+		if err := json.Unmarshal(over.Payload, &payload); err != nil {
+			t.Fatalf("Unmarshal error: %v", err)
+		}
+		if payload.Winner != game.Player2.String() || payload.Reason != lib.MatchEndForfeit {
+			t.Errorf("expected p2 to win by forfeit, got %+v", payload)
+		}
+
+		matches, err := testQueries.ListMatchesByUser(context.Background(), db.ListMatchesByUserParams{
+			P1ID: alice.ID, P2ID: alice.ID, Limit: 10,
+		})
+		if err != nil {
+			t.Fatalf("ListMatchesByUser error: %v", err)
+		}
+		if len(matches) != 1 || matches[0].EndReason != lib.MatchEndForfeit || matches[0].WinnerID.Int64 != bob.ID {
+			t.Errorf("expected a forfeit won by bob, got %+v", matches)
+		}
+	})
+
+	t.Run("ReleasedWhenEmpty", func(t *testing.T) {
+		_ = p2.conn.Close()
+		deadline := time.Now().Add(2 * time.Second)
+		for hub.roomCount() != 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected room to be removed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestHubSpectatorClockDelay(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{
+		SpectatorDelay: delay,
+		TimeLimits:     game.TimeLimits{Move: time.Minute},
+	})
+	hub.clockTick = 20 * time.Millisecond
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "clockwatch-alice")
+	bob := createTestUser(t, "clockwatch-bob")
+	carol := createTestUser(t, "clockwatch-carol")
+	roomID := createTestRoom(t, alice, bob)
+
+	watcher := dial(t, url, carol)
+	watcher.send(TypeJoinRoom, roomID, `{"role":"spectator"}`)
+	watcher.readUntil(TypeState)
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+
+	started := time.Now()
+	p1.readUntil(TypeClock)
+	if elapsed := time.Since(started); elapsed >= delay {
+		t.Fatalf("expected players to get ticks right away, waited %v", elapsed)
+	}
+	tick := watcher.readUntil(TypeClock)
+	if elapsed := time.Since(started); elapsed < delay {
+		t.Errorf("expected the tick to be held back %v, arrived after %v", delay, elapsed)
+	}
+	if tick.Seq != 0 {
+		t.Errorf("expected an unnumbered tick, got seq %d", tick.Seq)
+	}
+}
+
+func TestHubForfeit(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "resign-alice")
+	bob := createTestUser(t, "resign-bob")
+	roomID := createTestRoom(t, alice, bob)
+	ctx := context.Background()
+
+	room, err := testQueries.GetRoom(ctx, roomID)
+	if err != nil {
+		t.Fatalf("GetRoom error: %v", err)
+	}
+	// Nothing to forfeit before the match starts.
+	if err := hub.Forfeit(ctx, room, alice.ID); err != nil {
+		t.Fatalf("Forfeit error: %v", err)
+	}
+	if hub.roomCount() != 0 {
+		t.Error("expected the room opened for the forfeit to be released")
+	}
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+
+	if err := hub.Forfeit(ctx, room, alice.ID); err != nil {
+		t.Fatalf("Forfeit error: %v", err)
+	}
+	over := p2.readUntil(TypeGameOver)
+	var payload GameOverPayload
+	if err := json.Unmarshal(over.Payload, &payload); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if payload.Winner != game.Player2.String() || payload.Reason != lib.MatchEndForfeit {
+		t.Errorf("expected p2 to win by forfeit, got %+v", payload)
+	}
+
+	matches, err := testQueries.ListMatchesByUser(ctx, db.ListMatchesByUserParams{
+		P1ID: alice.ID, P2ID: alice.ID, Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("ListMatchesByUser error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].WinnerID.Int64 != bob.ID {
+		t.Errorf("expected a forfeit won by bob, got %+v", matches)
+	}
+	if room, err = testQueries.GetRoom(ctx, roomID); err != nil || room.Status != lib.RoomStatusFinished {
+		t.Errorf("expected the room to be finished, got %q (%v)", room.Status, err)
+	}
+
+	if err := hub.Forfeit(ctx, room, createTestUser(t, "resign-carol").ID); err == nil {
+		t.Error("expected an error for a user without a seat")
+	}
+}
+
+func TestHubTurnClock(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{
+		TimeLimits: game.TimeLimits{Move: 150 * time.Millisecond, MaxTimeouts: 2},
+	})
+	hub.clockTick = 20 * time.Millisecond
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "clock-alice")
+	bob := createTestUser(t, "clock-bob")
+	roomID := createTestRoom(t, alice, bob)
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+	p1.readUntil(TypeState)
+	p2 := dial(t, url, bob)
+	p2.send(TypeJoinRoom, roomID, "")
+	p2.readUntil(TypeState)
+
+	tick := p2.readUntil(TypeClock)
+	var clock ClockPayload
+	if err := json.Unmarshal(tick.Payload, &clock); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if clock.Player != game.Player1.String() || clock.RemainingMS <= 0 || clock.RemainingMS > 150 || tick.Seq != 0 {
+		t.Errorf("expected an unnumbered tick for p1 within the move limit, got %+v at seq %d", clock, tick.Seq)
+	}
+
+	over := p2.readUntil(TypeGameOver)
+	var payload GameOverPayload
+	if err := json.Unmarshal(over.Payload, &payload); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if payload.Winner != game.Player2.String() || payload.Reason != lib.MatchEndTimeout {
+		t.Errorf("expected p2 to win on time, got %+v", payload)
+	}
+
+	events, err := testQueries.ListMatchEvents(context.Background(), roomID)
+	if err != nil {
+		t.Fatalf("ListMatchEvents error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 automatic drops, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.Player != int64(game.Player1) || e.Action != game.ActionHardDrop.String() {
+			t.Errorf("expected a hard drop by p1, got %+v", e)
+		}
+	}
+
+	matches, err := testQueries.ListMatchesByUser(context.Background(), db.ListMatchesByUserParams{
+		P1ID: alice.ID, P2ID: alice.ID, Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("ListMatchesByUser error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].EndReason != lib.MatchEndTimeout {
+		t.Errorf("expected a match lost on time, got %+v", matches)
+	}
+}
+
+func TestHubBot(t *testing.T) {
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	hub.botDelay = 0
+	url := newTestServer(t, hub)
+	alice := createTestUser(t, "bot-alice")
+	botUser, err := lib.BotUser(context.Background(), testQueries)
+	if err != nil {
+		t.Fatalf("BotUser error: %v", err)
+	}
+	roomID := createTestRoom(t, alice, testUser{User: botUser})
+	dbRoom, err := testQueries.GetRoom(context.Background(), roomID)
+	if err != nil {
+		t.Fatalf("GetRoom error: %v", err)
+	}
+	if err := hub.StartBot(dbRoom, createTestUser(t, "bot-carol").User, bot.Normal); err == nil {
+		t.Error("expected error seating a bot outside the room")
+	}
+	if err := hub.StartBot(dbRoom, botUser, bot.Normal); err != nil {
+		t.Fatalf("StartBot error: %v", err)
+	}
+
+	p1 := dial(t, url, alice)
+	p1.send(TypeJoinRoom, roomID, "")
+
+	// Alice stacks the spawn column and loses; the bot has to play its
+	// turns for the match to get there.
+	botPlaced := false
+	for range 500 {
+		var state StatePayload
+		if err := json.Unmarshal(p1.readUntil(TypeState).Payload, &state); err != nil {
+			t.Fatalf("failed to decode state: %v", err)
+		}
+		for _, row := range state.Players[game.Player2].Board {
+			for _, cell := range row {
+				botPlaced = botPlaced || cell != ""
+			}
+		}
+		if state.Over {
+			if !botPlaced {
+				t.Error("expected the bot to have placed pairs")
+			}
+			if state.Winner != game.Player2.String() {
+				t.Errorf("expected the bot to win, got %q", state.Winner)
+			}
+			break
+		}
+		if state.Turn == game.Player1.String() && state.Players[game.Player1].Piece != nil {
+			p1.send(TypeInput, roomID, `{"action":"hard_drop"}`)
+		}
+	}
+
+	// The bot leaves once the match is over, so the room goes with Alice.
+	_ = p1.conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.roomCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected room to be removed after the match")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHubBotAbandoned(t *testing.T) {
+	ctx := context.Background()
+	botUser, err := lib.BotUser(ctx, testQueries)
+	if err != nil {
+		t.Fatalf("BotUser error: %v", err)
+	}
+	startBot := func(t *testing.T, hub *Hub, name string) (testUser, db.Room) {
+		t.Helper()
+		alice := createTestUser(t, name)
+		dbRoom, err := testQueries.GetRoom(ctx, createTestRoom(t, alice, testUser{User: botUser}))
+		if err != nil {
+			t.Fatalf("GetRoom error: %v", err)
+		}
+		if err := hub.StartBot(dbRoom, botUser, bot.Normal); err != nil {
+			t.Fatalf("StartBot error: %v", err)
+		}
+		return alice, dbRoom
+	}
+	waitReleased := func(t *testing.T, hub *Hub, roomID int64) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for hub.roomCount() != 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the bot to leave the room")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		room, err := testQueries.GetRoom(ctx, roomID)
+		if err != nil || room.Status != lib.RoomStatusFinished {
+			t.Errorf("expected the room to be finished, got %q (%v)", room.Status, err)
+		}
+	}
+
+	t.Run("NeverJoined", func(t *testing.T) {
+		hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+		hub.botJoinTimeout = 50 * time.Millisecond
+		_, dbRoom := startBot(t, hub, "abandon-alice")
+		waitReleased(t, hub, dbRoom.ID)
+	})
+
+	t.Run("LeftBeforeStart", func(t *testing.T) {
+		hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+		alice, dbRoom := startBot(t, hub, "abandon-bob")
+		if err := hub.Forfeit(ctx, dbRoom, alice.ID); err != nil {
+			t.Fatalf("Forfeit error: %v", err)
+		}
+		// Leaving through the API finishes the room; the hub only has to
+		// let the bot go.
+		if _, err := testQueries.UpdateRoomStatus(ctx, db.UpdateRoomStatusParams{
+			Status: lib.RoomStatusFinished,
+			ID:     dbRoom.ID,
+		}); err != nil {
+			t.Fatalf("UpdateRoomStatus error: %v", err)
+		}
+		waitReleased(t, hub, dbRoom.ID)
+	})
+}
+
+// createTestBotRoom creates a room with alice against a bot of level,
+// stored the way POST /api/rooms stores it.
+func createTestBotRoom(t *testing.T, alice testUser, level bot.Level) (db.Room, db.User) {
+	t.Helper()
+	ctx := context.Background()
+	botUser, err := lib.BotUser(ctx, testQueries)
+	if err != nil {
+		t.Fatalf("BotUser error: %v", err)
+	}
+	room, err := testQueries.CreateRoom(ctx, db.CreateRoomParams{
+		P1ID:        sql.NullInt64{Int64: alice.ID, Valid: true},
+		Status:      lib.RoomStatusWaiting,
+		Seed:        testSeed,
+		SharedQueue: true,
+		BotLevel:    level.String(),
+	})
+	if err != nil {
+		t.Fatalf("CreateRoom error: %v", err)
+	}
+	room, err = testQueries.JoinRoom(ctx, db.JoinRoomParams{
+		P2ID: sql.NullInt64{Int64: botUser.ID, Valid: true},
+		ID:   room.ID,
+	})
+	if err != nil {
+		t.Fatalf("JoinRoom error: %v", err)
+	}
+	return room, botUser
+}
+
+func TestHubResumesBotRoom(t *testing.T) {
+	alice := createTestUser(t, "resume-bot-alice")
+	dbRoom, botUser := createTestBotRoom(t, alice, bot.Easy)
+
+	before := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{ReconnectGrace: time.Minute})
+	before.botDelay = 0
+	if err := before.StartBot(dbRoom, botUser, bot.Easy); err != nil {
+		t.Fatalf("StartBot error: %v", err)
+	}
+	p1 := dial(t, newTestServer(t, before), alice)
+	p1.send(TypeJoinRoom, dbRoom.ID, "")
+	p1.readUntil(TypeState)
+	p1.send(TypeInput, dbRoom.ID, `{"action":"hard_drop"}`)
+	p1.readUntil(TypeState)
+
+	// After a restart the bot is seated again as soon as the room opens,
+	// so its seat is never held and never forfeited.
+	after := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{ReconnectGrace: time.Minute})
+	after.botDelay = 0
+	p1 = dial(t, newTestServer(t, after), alice)
+	p1.send(TypeJoinRoom, dbRoom.ID, "")
+	env := p1.readUntil(TypeState)
+
+	after.mu.Lock()
+	room := after.rooms[dbRoom.ID]
+	after.mu.Unlock()
+	if !room.seated(game.Player2) {
+		t.Fatal("expected the bot to be seated again")
+	}
+
+	for range 200 {
+		if env.Type == TypePresence {
+			if presence := decodePresence(t, env); presence.Player == game.Player2.String() {
+				t.Fatalf("expected no presence change for the bot, got %+v", presence)
+			}
+		}
+		if env.Type != TypeState {
+			env = p1.read()
+			continue
+		}
+		var state StatePayload
+		if err := json.Unmarshal(env.Payload, &state); err != nil {
+			t.Fatalf("failed to decode state: %v", err)
+		}
+		if state.Over {
+			t.Fatalf("expected the match to go on, got winner %q", state.Winner)
+		}
+		if state.Turn == game.Player2.String() {
+			// The bot has the turn; it has to play it for the match to go
+			// on.
+			p1.readUntil(TypeTurnChange)
+			return
+		}
+		if state.Players[game.Player1].Piece != nil {
+			p1.send(TypeInput, dbRoom.ID, `{"action":"hard_drop"}`)
+		}
+		env = p1.read()
+	}
+	t.Fatal("expected the turn to reach the bot")
+}
+
+func TestHubResumeBots(t *testing.T) {
+	ctx := context.Background()
+	alice := createTestUser(t, "resume-bots-alice")
+	dbRoom, _ := createTestBotRoom(t, alice, bot.Easy)
+
+	// The bot was never started, as when the server died right after the
+	// room was created.
+	hub := NewHub(testDB, testQueries, lib.DefaultSessionPolicy(), Options{})
+	hub.botJoinTimeout = 200 * time.Millisecond
+	if err := hub.ResumeBots(ctx); err != nil {
+		t.Fatalf("ResumeBots error: %v", err)
+	}
+	hub.mu.Lock()
+	room := hub.rooms[dbRoom.ID]
+	hub.mu.Unlock()
+	if room == nil || !room.seated(game.Player2) {
+		t.Fatal("expected the bot to be seated")
+	}
+
+	// Nobody comes, so the bot gives up and the room is finished.
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.roomCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the bot to leave the room")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, err := testQueries.GetRoom(ctx, dbRoom.ID); err != nil || got.Status != lib.RoomStatusFinished {
+		t.Errorf("expected the room to be finished, got %q (%v)", got.Status, err)
+	}
+}
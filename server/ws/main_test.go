@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/sodefrin/PP/server/db"
+
+	_ "modernc.org/sqlite"
+)
+
+var (
+	testDB      *sql.DB
+	testQueries *db.Queries
+)
+
+func TestMain(m *testing.M) {
+	// Setup DB
+	dbConn, err := sql.Open("sqlite", "file::memory:?cache=shared")
+	if err != nil {
+		slog.ErrorContext(context.Background(), "Failed to open database", "error", err)
+		os.Exit(1)
+	}
+
+	// Apply migrations
+	if err := db.Migrate(context.Background(), dbConn); err != nil {
+		slog.ErrorContext(context.Background(), "Failed to apply migrations", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize queries
+	testDB = dbConn
+	testQueries = db.New(dbConn)
+
+	code := m.Run()
+
+	if err := dbConn.Close(); err != nil {
+		slog.ErrorContext(context.Background(), "Failed to close database", "error", err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,273 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sodefrin/PP/server/game"
+)
+
+const Version = 1
+
+// Client to server message types.
+const (
+	TypeJoinRoom = "join_room"
+	TypeInput    = "input"
+)
+
+// Server to client message types.
+const (
+	TypeState      = "state"
+	TypeChain      = "chain"
+	TypeNuisance   = "nuisance"
+	TypeTurnChange = "turn_change"
+	TypeGameOver   = "game_over"
+	TypeMatchFound = "match_found"
+	TypePresence   = "presence"
+	TypeClock      = "clock"
+	TypeError      = "error"
+)
+
+// Error codes sent in ErrorPayload.
+const (
+	CodeMalformed          = "malformed"
+	CodeUnsupportedVersion = "unsupported_version"
+	CodeOutOfOrder         = "out_of_order"
+	CodeUnknownType        = "unknown_type"
+	CodeInvalidPayload     = "invalid_payload"
+	CodeNotJoined          = "not_joined"
+	CodeAlreadyJoined      = "already_joined"
+	CodeRoomNotFound       = "room_not_found"
+	CodeRoomClosed         = "room_closed"
+	CodeForbidden          = "forbidden"
+	CodeReadOnly           = "read_only"
+	CodeNotStarted         = "not_started"
+	CodeNotYourTurn        = "not_your_turn"
+	CodeNoMovesLeft        = "no_moves_left"
+	CodeGameOver           = "game_over"
+	CodeUnavailable        = "unavailable"
+)
+
+type Envelope struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	Seq     uint64          `json:"seq"`
+	RoomID  int64           `json:"room_id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Roles a connection may join a room with.
+const (
+	RolePlayer    = "player"
+	RoleSpectator = "spectator"
+)
+
+// JoinRoomPayload selects the role to join with. An empty role means
+// RolePlayer. A player coming back after a dropped connection sets
+// LastSeq to the last room seq it received to get only what it missed.
+type JoinRoomPayload struct {
+	Role    string  `json:"role,omitempty"`
+	LastSeq *uint64 `json:"last_seq,omitempty"`
+}
+
+type InputPayload struct {
+	Action string `json:"action"`
+}
+
+type PiecePayload struct {
+	Main     string `json:"main"`
+	Sub      string `json:"sub"`
+	Row      int    `json:"row"`
+	Col      int    `json:"col"`
+	Rotation int    `json:"rotation"`
+}
+
+type PairPayload struct {
+	Main string `json:"main"`
+	Sub  string `json:"sub"`
+}
+
+type PlayerState struct {
+	Player    string        `json:"player"`
+	Board     [][]string    `json:"board"`
+	Piece     *PiecePayload `json:"piece"`
+	Next      []PairPayload `json:"next"`
+	MovesLeft int           `json:"moves_left"`
+	Pending   int           `json:"pending"`
+	Score     int           `json:"score"`
+	MaxChain  int           `json:"max_chain"`
+}
+
+type StatePayload struct {
+	Turn      string        `json:"turn"`
+	TurnCount int           `json:"turn_count"`
+	Players   []PlayerState `json:"players"`
+	Over      bool          `json:"over"`
+	Winner    string        `json:"winner,omitempty"`
+}
+
+type ChainPayload struct {
+	Player  string `json:"player"`
+	Chain   int    `json:"chain"`
+	Score   int    `json:"score"`
+	Cleared int    `json:"cleared"`
+	Garbage int    `json:"garbage"`
+}
+
+// NuisancePayload describes how the nuisance of the player whose turn
+// ended was settled. Pending maps each player to the nuisance still
+// waiting to fall on them.
+type NuisancePayload struct {
+	Player    string         `json:"player"`
+	Generated int            `json:"generated"`
+	Offset    int            `json:"offset"`
+	Sent      int            `json:"sent"`
+	Dropped   int            `json:"dropped"`
+	Pending   map[string]int `json:"pending"`
+}
+
+type TurnChangePayload struct {
+	Turn      string         `json:"turn"`
+	TurnCount int            `json:"turn_count"`
+	MovesLeft map[string]int `json:"moves_left"`
+}
+
+type GameOverPayload struct {
+	Winner string `json:"winner"`
+	Reason string `json:"reason"`
+}
+
+// PresencePayload reports a player losing or regaining their connection.
+// While a player is away their seat is held until ForfeitAt.
+type PresencePayload struct {
+	Player    string     `json:"player"`
+	Connected bool       `json:"connected"`
+	ForfeitAt *time.Time `json:"forfeit_at,omitempty"`
+}
+
+// ClockPayload is a tick of the turn clock: the player on turn, the time
+// left until their pair is dropped for them, and each player's run of
+// consecutive timeouts.
+type ClockPayload struct {
+	Player      string         `json:"player"`
+	RemainingMS int64          `json:"remaining_ms"`
+	Timeouts    map[string]int `json:"timeouts"`
+}
+
+// MatchFoundPayload tells a queued player which seat they have in the
+// room given by the envelope's room_id.
+type MatchFoundPayload struct {
+	Player     string `json:"player"`
+	OpponentID int64  `json:"opponent_id"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	RefSeq  uint64 `json:"ref_seq,omitempty"`
+}
+
+// ProtocolError is a problem with a client frame that is reported back to
+// the client instead of closing the connection.
+type ProtocolError struct {
+	Code    string
+	Message string
+	RefSeq  uint64
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *ProtocolError) Payload() ErrorPayload {
+	return ErrorPayload{Code: e.Code, Message: e.Message, RefSeq: e.RefSeq}
+}
+
+// Decoder validates client frames. A frame consumes its seq once it
+// passes the version and order checks, even if its type or payload is
+// then rejected.
+type Decoder struct {
+	lastSeq uint64
+}
+
+func (d *Decoder) LastSeq() uint64 {
+	return d.lastSeq
+}
+
+func (d *Decoder) Decode(data []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, &ProtocolError{Code: CodeMalformed, Message: "frame is not a valid envelope"}
+	}
+	if env.Version != Version {
+		return Envelope{}, &ProtocolError{
+			Code:    CodeUnsupportedVersion,
+			Message: fmt.Sprintf("unsupported protocol version %d", env.Version),
+			RefSeq:  env.Seq,
+		}
+	}
+	if env.Seq != d.lastSeq+1 {
+		return Envelope{}, &ProtocolError{
+			Code:    CodeOutOfOrder,
+			Message: fmt.Sprintf("expected seq %d, got %d", d.lastSeq+1, env.Seq),
+			RefSeq:  env.Seq,
+		}
+	}
+	d.lastSeq = env.Seq
+
+	switch env.Type {
+	case TypeJoinRoom, TypeInput:
+	default:
+		return Envelope{}, &ProtocolError{
+			Code:    CodeUnknownType,
+			Message: fmt.Sprintf("unknown message type %q", env.Type),
+			RefSeq:  env.Seq,
+		}
+	}
+	return env, nil
+}
+
+// DecodePayload unmarshals the payload of env into v.
+func DecodePayload(env Envelope, v any) error {
+	if len(env.Payload) == 0 {
+		env.Payload = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(env.Payload, v); err != nil {
+		return &ProtocolError{Code: CodeInvalidPayload, Message: "invalid payload", RefSeq: env.Seq}
+	}
+	return nil
+}
+
+// Encode builds a server frame.
+func Encode(msgType string, seq uint64, roomID int64, payload any) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		Version: Version,
+		Type:    msgType,
+		Seq:     seq,
+		RoomID:  roomID,
+		Payload: raw,
+	})
+}
+
+// gameError maps an error returned by game.Match, or by Room.play, to a
+// protocol error.
+func gameError(err error, refSeq uint64) *ProtocolError {
+	code := CodeInvalidPayload
+	switch {
+	case errors.Is(err, game.ErrNotYourTurn):
+		code = CodeNotYourTurn
+	case errors.Is(err, game.ErrNoMovesLeft):
+		code = CodeNoMovesLeft
+	case errors.Is(err, game.ErrGameOver):
+		code = CodeGameOver
+	case errors.Is(err, errNotStored):
+		code = CodeUnavailable
+	}
+	return &ProtocolError{Code: code, Message: err.Error(), RefSeq: refSeq}
+}
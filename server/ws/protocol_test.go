@@ -0,0 +1,109 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sodefrin/PP/server/game"
+)
+
+func decodeCode(t *testing.T, d *Decoder, frame string) string {
+	t.Helper()
+	_, err := d.Decode([]byte(frame))
+	if err == nil {
+		return ""
+	}
+	var perr *ProtocolError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected ProtocolError, got %v", err)
+	}
+	return perr.Code
+}
+
+func TestDecoder(t *testing.T) {
+	var d Decoder
+
+	tests := []struct {
+		name  string
+		frame string
+		want  string
+	}{
+		{"Malformed", `{"v":1,`, CodeMalformed},
+		{"WrongVersion", `{"v":2,"type":"input","seq":1}`, CodeUnsupportedVersion},
+		{"SkipsSeq", `{"v":1,"type":"join_room","seq":2}`, CodeOutOfOrder},
+		{"First", `{"v":1,"type":"join_room","seq":1,"room_id":7}`, ""},
+		{"Replayed", `{"v":1,"type":"join_room","seq":1}`, CodeOutOfOrder},
+		{"UnknownType", `{"v":1,"type":"teleport","seq":2}`, CodeUnknownType},
+		{"AfterUnknownType", `{"v":1,"type":"input","seq":3,"payload":{"action":"left"}}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeCode(t, &d, tt.frame); got != tt.want {
+				t.Errorf("expected code %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if d.LastSeq() != 3 {
+		t.Errorf("expected last seq 3, got %d", d.LastSeq())
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	env := Envelope{Seq: 4, Payload: json.RawMessage(`{"action":"rotate_cw"}`)}
+	var input InputPayload
+	if err := DecodePayload(env, &input); err != nil {
+		t.Fatalf("DecodePayload error: %v", err)
+	}
+	if input.Action != "rotate_cw" {
+		t.Errorf("expected rotate_cw, got %s", input.Action)
+	}
+
+	env.Payload = json.RawMessage(`[1,2]`)
+	var perr *ProtocolError
+	if err := DecodePayload(env, &input); !errors.As(err, &perr) || perr.Code != CodeInvalidPayload || perr.RefSeq != 4 {
+		t.Errorf("expected invalid_payload for seq 4, got %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	frame, err := Encode(TypeGameOver, 9, 3, GameOverPayload{Winner: "p1", Reason: "topout"})
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(frame, &env); err != nil {
+		t.Fatalf("failed to decode frame: %v", err)
+	}
+	if env.Version != Version || env.Type != TypeGameOver || env.Seq != 9 || env.RoomID != 3 {
+		t.Errorf("unexpected envelope: %+v", env)
+	}
+	var payload GameOverPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if payload.Winner != "p1" || payload.Reason != "topout" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestGameError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{game.ErrNotYourTurn, CodeNotYourTurn},
+		{game.ErrNoMovesLeft, CodeNoMovesLeft},
+		{game.ErrGameOver, CodeGameOver},
+		{game.ErrUnknownAction, CodeInvalidPayload},
+		{errNotStored, CodeUnavailable},
+	}
+	for _, tt := range tests {
+		if got := gameError(tt.err, 1).Code; got != tt.want {
+			t.Errorf("%v: expected %s, got %s", tt.err, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,608 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sodefrin/PP/server/db"
+	"github.com/sodefrin/PP/server/game"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// Room owns the authoritative match of one room and fans its events out
+// to every connected member.
+type Room struct {
+	id        int64
+	conn      *sql.DB
+	queries   *db.Queries
+	userIDs   [2]int64
+	seed      int64
+	shared    bool
+	opts      Options
+	clockTick time.Duration
+	// lobby is the state shown before the match starts: empty boards and
+	// the pairs the match will begin with.
+	lobby StatePayload
+	// onIdle is called, without r.mu held, when the room empties after a
+	// forfeit so that the hub can drop it.
+	onIdle func(*Room)
+
+	mu sync.Mutex
+	// match is nil until both players are seated at the same time; until
+	// then inputs are refused.
+	match      *game.Match
+	startedAt  time.Time
+	players    [2]*Client
+	spectators map[*Client]struct{}
+	seq        uint64
+	// inputs counts the inputs stored in match_events.
+	inputs int64
+	// history holds the last historySize broadcasts for players resuming
+	// after a dropped connection.
+	history []historyEntry
+	// holds keeps the seat of a disconnected player until they come back
+	// or forfeit.
+	holds [2]*seatHold
+	// clock is nil when the hub has no time limits.
+	clock *game.Clock
+
+	// feed holds broadcasts spectators have yet to see, oldest first.
+	// shownSeq and shownState describe the room as spectators last saw it
+	// and are what a new spectator starts from.
+	feed       []feedEntry
+	feedTimer  *time.Timer
+	shownSeq   uint64
+	shownState StatePayload
+}
+
+// errNotStored is returned for an input that was refused because it could
+// not be stored.
+var errNotStored = errors.New("input could not be stored, try again")
+
+// historySize is how many broadcast frames a resuming player can catch up
+// on before falling back to a snapshot.
+const historySize = 256
+
+type historyEntry struct {
+	seq uint64
+	msg Message
+}
+
+type seatHold struct {
+	timer *time.Timer
+}
+
+// feedEntry is one broadcast waiting to be shown to spectators. With
+// notice set it holds unnumbered frames, such as clock ticks, instead.
+type feedEntry struct {
+	due    time.Time
+	msgs   []Message
+	first  uint64
+	state  StatePayload
+	notice bool
+}
+
+// newRoom opens dbRoom. Its match starts once both players are seated.
+func newRoom(dbRoom db.Room, conn *sql.DB, queries *db.Queries, opts Options, clockTick time.Duration, onIdle func(*Room)) *Room {
+	lobby := NewState(game.NewMatch(game.NewGenerator(uint64(dbRoom.Seed), dbRoom.SharedQueue)))
+	var clock *game.Clock
+	if opts.TimeLimits.Enabled() {
+		clock = game.NewClock(opts.TimeLimits)
+	}
+	return &Room{
+		id:         dbRoom.ID,
+		conn:       conn,
+		queries:    queries,
+		userIDs:    [2]int64{dbRoom.P1ID.Int64, dbRoom.P2ID.Int64},
+		seed:       dbRoom.Seed,
+		shared:     dbRoom.SharedQueue,
+		opts:       opts,
+		clockTick:  clockTick,
+		onIdle:     onIdle,
+		lobby:      lobby,
+		clock:      clock,
+		spectators: make(map[*Client]struct{}),
+		shownState: lobby,
+	}
+}
+
+// state is a snapshot of the match, or of the lobby before it starts.
+// Callers must hold r.mu.
+func (r *Room) state() StatePayload {
+	if r.match == nil {
+		return r.lobby
+	}
+	return NewState(r.match)
+}
+
+// start creates the match once both players recorded for the room are
+// seated, and starts the clock. Callers must hold r.mu.
+func (r *Room) start() {
+	if r.players[game.Player1] == nil || r.players[game.Player2] == nil {
+		return
+	}
+	if r.match == nil {
+		if r.userIDs[game.Player1] == 0 || r.userIDs[game.Player2] == 0 {
+			return
+		}
+		r.match = game.NewMatch(game.NewGenerator(uint64(r.seed), r.shared))
+		r.startedAt = time.Now()
+	}
+	r.startClock()
+}
+
+// resume replays the inputs stored for the room, oldest first, so that a
+// match reopened after its room was let go goes on where it was instead
+// of starting over. Later inputs are numbered after the stored ones.
+func (r *Room) resume(events []db.MatchEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	inputs := make([]game.Input, 0, len(events))
+	for _, e := range events {
+		action, err := game.ParseAction(e.Action)
+		if err != nil {
+			return fmt.Errorf("resume room %d: %w", r.id, err)
+		}
+		inputs = append(inputs, game.Input{Player: game.Player(e.Player), Turn: int(e.Turn), Action: action})
+	}
+	match, _, err := game.Replay(uint64(r.seed), r.shared, inputs)
+	if err != nil {
+		return fmt.Errorf("resume room %d: %w", r.id, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.match = match
+	r.inputs = events[len(events)-1].Seq
+	// The start itself is not stored; the first input is close to it.
+	r.startedAt = events[0].CreatedAt
+	r.shownState = NewState(match)
+	return nil
+}
+
+func (r *Room) empty() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.emptyLocked()
+}
+
+// emptyLocked reports whether nobody is connected and no seat is held.
+func (r *Room) emptyLocked() bool {
+	for p := range r.players {
+		if r.players[p] != nil || r.holds[p] != nil {
+			return false
+		}
+	}
+	return len(r.spectators) == 0
+}
+
+// seated reports whether someone is connected in the seat of p.
+func (r *Room) seated(p game.Player) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.players[p] != nil
+}
+
+func (r *Room) spectatorCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.spectators)
+}
+
+// join seats c as player. With lastSeq set, c is sent the broadcasts it
+// missed under their original seqs; otherwise, or if they are no longer
+// buffered, it gets a snapshot numbered with the room's current seq.
+//
+// A connection already in the seat is closed: it is most likely a socket
+// that dropped without the server noticing yet.
+func (r *Room) join(c *Client, player game.Player, userIDs [2]int64, lastSeq *uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The second player may have joined through /api/rooms since the room
+	// was started.
+	r.userIDs = userIDs
+	if old := r.players[player]; old != nil {
+		old.stop(websocket.CloseNormalClosure, "replaced by a new connection")
+	}
+	r.players[player] = c
+	c.room = r
+	c.player = player
+
+	if lastSeq == nil || !r.catchUp(c, *lastSeq) {
+		r.sendTo(c, r.seq, Message{TypeState, r.state()})
+	}
+	if hold := r.holds[player]; hold != nil {
+		hold.timer.Stop()
+		r.holds[player] = nil
+		r.broadcast(Message{TypePresence, PresencePayload{Player: player.String(), Connected: true}})
+	}
+	// In a resumed match the opponent may not have come back yet; they get
+	// the same grace as after a dropped connection.
+	if opp := player.Opponent(); r.players[opp] == nil && r.holds[opp] == nil {
+		r.hold(opp)
+	}
+	r.start()
+}
+
+// catchUp sends c every buffered broadcast after lastSeq and reports
+// whether that covered everything it missed. Callers must hold r.mu.
+func (r *Room) catchUp(c *Client, lastSeq uint64) bool {
+	if lastSeq > r.seq {
+		return false
+	}
+	if lastSeq < r.seq && (len(r.history) == 0 || r.history[0].seq > lastSeq+1) {
+		return false
+	}
+	for _, h := range r.history {
+		if h.seq > lastSeq {
+			r.sendTo(c, h.seq, h.msg)
+		}
+	}
+	return true
+}
+
+// spectate adds c as a spectator and sends it the room as spectators
+// currently see it.
+func (r *Room) spectate(c *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spectators[c] = struct{}{}
+	c.room = r
+	c.spectator = true
+	r.sendTo(c, r.shownSeq, Message{TypeState, r.shownState})
+}
+
+// leave removes c and reports whether the room is now empty. A player
+// leaving a match in progress keeps their seat for the reconnect grace
+// period and forfeits if they do not come back.
+func (r *Room) leave(c *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for p, member := range r.players {
+		if member == c {
+			r.players[p] = nil
+			r.hold(game.Player(p))
+		}
+	}
+	delete(r.spectators, c)
+	if !r.emptyLocked() {
+		return false
+	}
+	if r.feedTimer != nil {
+		r.feedTimer.Stop()
+		r.feedTimer = nil
+	}
+	return true
+}
+
+// hold keeps the seat of p, who just disconnected. Callers must hold r.mu.
+func (r *Room) hold(p game.Player) {
+	// Nothing is at stake before the match starts or after it ends.
+	if r.match == nil || r.match.Over() {
+		return
+	}
+	if r.opts.ReconnectGrace <= 0 {
+		r.forfeit(p, lib.MatchEndForfeit)
+		return
+	}
+
+	hold := &seatHold{}
+	hold.timer = time.AfterFunc(r.opts.ReconnectGrace, func() { r.expire(p, hold) })
+	r.holds[p] = hold
+	forfeitAt := time.Now().Add(r.opts.ReconnectGrace).UTC()
+	r.broadcast(Message{TypePresence, PresencePayload{Player: p.String(), ForfeitAt: &forfeitAt}})
+}
+
+// expire forfeits p if hold still keeps their seat.
+func (r *Room) expire(p game.Player, hold *seatHold) {
+	r.mu.Lock()
+	if r.holds[p] != hold {
+		r.mu.Unlock()
+		return
+	}
+	r.holds[p] = nil
+	r.forfeit(p, lib.MatchEndForfeit)
+	idle := r.emptyLocked()
+	r.mu.Unlock()
+
+	if idle && r.onIdle != nil {
+		r.onIdle(r)
+	}
+}
+
+// forfeit ends the match with p losing for reason and records it. Callers
+// must hold r.mu.
+func (r *Room) forfeit(p game.Player, reason string) {
+	if r.match == nil || r.match.Forfeit(p) != nil {
+		return
+	}
+	for i, hold := range r.holds {
+		if hold != nil {
+			hold.timer.Stop()
+			r.holds[i] = nil
+		}
+	}
+	r.broadcast(
+		Message{TypeGameOver, GameOverPayload{Winner: r.match.Winner().String(), Reason: reason}},
+		Message{TypeState, NewState(r.match)},
+	)
+	r.record(context.Background(), reason)
+}
+
+// resign forfeits the match in progress for p, who left the room for
+// good. Before the start there is no match to forfeit, and a bot waiting
+// for p has nobody left to play.
+func (r *Room) resign(p game.Player) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.match == nil {
+		r.dropBotsLocked()
+		return
+	}
+	r.forfeit(p, lib.MatchEndForfeit)
+}
+
+// dropBots stops the bots seated in a room whose match has not started
+// and reports whether there were any.
+func (r *Room) dropBots() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.match != nil {
+		return false
+	}
+	return r.dropBotsLocked()
+}
+
+// dropBotsLocked frees the seats of bots and stops them; their goroutines
+// then leave the hub. Callers must hold r.mu.
+func (r *Room) dropBotsLocked() bool {
+	dropped := false
+	for p, c := range r.players {
+		if c != nil && c.conn == nil {
+			r.players[p] = nil
+			c.stop(websocket.CloseNormalClosure, "room abandoned")
+			dropped = true
+		}
+	}
+	return dropped
+}
+
+// startClock starts the turn clock with the match. Callers must hold r.mu.
+func (r *Room) startClock() {
+	if r.clock == nil || r.clock.Started() || r.match.Over() {
+		return
+	}
+	r.clock.Start(time.Now())
+	go r.runClock()
+}
+
+func (r *Room) runClock() {
+	ticker := time.NewTicker(r.clockTick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !r.tick() {
+			return
+		}
+	}
+}
+
+// tick hard drops for a player who ran out of time, then tells every
+// member how much time is left, spectators after the spectator delay. It
+// reports whether the clock is still running.
+func (r *Room) tick() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx := context.Background()
+	now := time.Now()
+	// A drop that cannot be stored is not made; the next tick retries it.
+	p, results, forfeit, err := r.clock.Expire(r.match, now, func(p game.Player, a game.Action) (game.Result, error) {
+		return r.play(ctx, p, a)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Clock expiry error", "room_id", r.id, "error", err)
+	}
+	for _, res := range results {
+		r.broadcast(ResultMessages(r.match, p, res)...)
+		if res.GameOver {
+			r.record(ctx, lib.MatchEndTopOut)
+		}
+	}
+	if forfeit {
+		r.forfeit(p, lib.MatchEndTimeout)
+	}
+
+	deadline, ok := r.clock.Deadline(r.match)
+	if !ok {
+		return false
+	}
+	msg := Message{TypeClock, ClockPayload{
+		Player:      r.match.Turn().String(),
+		RemainingMS: max(deadline.Sub(now), 0).Milliseconds(),
+		Timeouts: map[string]int{
+			game.Player1.String(): r.clock.Timeouts(game.Player1),
+			game.Player2.String(): r.clock.Timeouts(game.Player2),
+		},
+	}}
+	for _, c := range r.players {
+		if c != nil {
+			c.notify(r.id, msg)
+		}
+	}
+	r.feedSpectators(feedEntry{msgs: []Message{msg}, notice: true})
+	return true
+}
+
+func (r *Room) input(ctx context.Context, c *Client, refSeq uint64, payload InputPayload) error {
+	action, err := game.ParseAction(payload.Action)
+	if err != nil {
+		return &ProtocolError{Code: CodeInvalidPayload, Message: err.Error(), RefSeq: refSeq}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.players[c.player] != c {
+		return &ProtocolError{Code: CodeNotJoined, Message: "seat taken over by another connection", RefSeq: refSeq}
+	}
+	if r.match == nil {
+		return &ProtocolError{Code: CodeNotStarted, Message: "waiting for both players to join", RefSeq: refSeq}
+	}
+
+	res, err := r.play(context.WithoutCancel(ctx), c.player, action)
+	if err != nil {
+		return gameError(err, refSeq)
+	}
+	if r.clock != nil {
+		r.clock.Played(c.player, res, time.Now())
+	}
+	r.broadcast(ResultMessages(r.match, c.player, res)...)
+	if res.GameOver {
+		// The result must be stored even if this player disconnects now.
+		r.record(context.WithoutCancel(ctx), lib.MatchEndTopOut)
+	}
+	return nil
+}
+
+// play stores action in match_events and then applies it for player, so
+// that the stored inputs always replay to the live match. An action the
+// match would refuse is not stored, and one that cannot be stored is not
+// applied. Callers must hold r.mu.
+func (r *Room) play(ctx context.Context, player game.Player, action game.Action) (game.Result, error) {
+	if err := r.match.Check(player, action); err != nil {
+		return game.Result{}, err
+	}
+	if err := r.queries.CreateMatchEvent(ctx, db.CreateMatchEventParams{
+		RoomID:    r.id,
+		Seq:       r.inputs + 1,
+		Player:    int64(player),
+		Turn:      int64(r.match.TurnCount()),
+		Action:    action.String(),
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		slog.ErrorContext(ctx, "CreateMatchEvent error", "room_id", r.id, "error", err)
+		return game.Result{}, errNotStored
+	}
+	r.inputs++
+	return r.match.Apply(player, action)
+}
+
+// record stores the finished match, closes the room and updates ratings.
+// Failures are logged; the players have already been told the result.
+// Callers must hold r.mu.
+func (r *Room) record(ctx context.Context, reason string) {
+	m := r.match
+	params := db.CreateMatchParams{
+		RoomID:     r.id,
+		P1ID:       r.userIDs[game.Player1],
+		P2ID:       r.userIDs[game.Player2],
+		P1Score:    int64(m.Score(game.Player1)),
+		P2Score:    int64(m.Score(game.Player2)),
+		P1MaxChain: int64(m.MaxChain(game.Player1)),
+		P2MaxChain: int64(m.MaxChain(game.Player2)),
+		Seed:       r.seed,
+		EndReason:  reason,
+		StartedAt:  r.startedAt.UTC(),
+		EndedAt:    time.Now().UTC(),
+	}
+	if m.Over() {
+		params.WinnerID = sql.NullInt64{Int64: r.userIDs[m.Winner()], Valid: true}
+	}
+	if _, err := lib.RecordMatch(ctx, r.conn, r.queries, params); err != nil {
+		slog.ErrorContext(ctx, "RecordMatch error", "room_id", r.id, "error", err)
+	}
+}
+
+// broadcast numbers msgs with the room seq, queues them for both players
+// and hands them to spectators after the spectator delay. Callers must hold
+// r.mu so that all members see the same order.
+func (r *Room) broadcast(msgs ...Message) {
+	first := r.seq + 1
+	for _, msg := range msgs {
+		r.seq++
+		for _, c := range r.players {
+			if c != nil {
+				r.sendTo(c, r.seq, msg)
+			}
+		}
+		r.history = append(r.history, historyEntry{seq: r.seq, msg: msg})
+	}
+	if n := len(r.history) - historySize; n > 0 {
+		r.history = append(r.history[:0:0], r.history[n:]...)
+	}
+
+	r.feedSpectators(feedEntry{msgs: msgs, first: first, state: r.state()})
+}
+
+// feedSpectators shows entry to spectators once the spectator delay has
+// passed. Callers must hold r.mu.
+func (r *Room) feedSpectators(entry feedEntry) {
+	if r.opts.SpectatorDelay <= 0 {
+		r.show(entry)
+		return
+	}
+	entry.due = time.Now().Add(r.opts.SpectatorDelay)
+	r.feed = append(r.feed, entry)
+	if r.feedTimer == nil {
+		r.feedTimer = time.AfterFunc(r.opts.SpectatorDelay, r.flushFeed)
+	}
+}
+
+// flushFeed shows spectators every broadcast that is due and schedules
+// itself for the next one.
+func (r *Room) flushFeed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for len(r.feed) > 0 && !r.feed[0].due.After(now) {
+		r.show(r.feed[0])
+		r.feed = r.feed[1:]
+	}
+	if len(r.feed) == 0 {
+		r.feedTimer = nil
+		return
+	}
+	r.feedTimer = time.AfterFunc(r.feed[0].due.Sub(now), r.flushFeed)
+}
+
+// show sends entry to every spectator. Callers must hold r.mu.
+func (r *Room) show(entry feedEntry) {
+	if entry.notice {
+		for _, msg := range entry.msgs {
+			for c := range r.spectators {
+				c.notify(r.id, msg)
+			}
+		}
+		return
+	}
+	for i, msg := range entry.msgs {
+		for c := range r.spectators {
+			r.sendTo(c, entry.first+uint64(i), msg)
+		}
+	}
+	r.shownSeq = entry.first + uint64(len(entry.msgs)) - 1
+	r.shownState = entry.state
+}
+
+func (r *Room) sendTo(c *Client, seq uint64, msg Message) {
+	frame, err := Encode(msg.Type, seq, r.id, msg.Payload)
+	if err != nil {
+		slog.Error("Failed to encode websocket frame", "room_id", r.id, "error", err)
+		return
+	}
+	c.enqueue(frame)
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"github.com/sodefrin/PP/server/game"
+	"github.com/sodefrin/PP/server/lib"
+)
+
+// Message is an outbound frame before it is numbered and encoded.
+type Message struct {
+	Type    string
+	Payload any
+}
+
+func NewState(m *game.Match) StatePayload {
+	state := StatePayload{
+		Turn:      m.Turn().String(),
+		TurnCount: m.TurnCount(),
+		Over:      m.Over(),
+	}
+	if m.Over() {
+		state.Winner = m.Winner().String()
+	}
+	for _, p := range []game.Player{game.Player1, game.Player2} {
+		state.Players = append(state.Players, newPlayerState(m, p))
+	}
+	return state
+}
+
+func newPlayerState(m *game.Match, p game.Player) PlayerState {
+	cells := m.Board(p).Cells()
+	board := make([][]string, game.Rows)
+	for r := range cells {
+		board[r] = make([]string, game.Cols)
+		for c, color := range cells[r] {
+			board[r][c] = color.String()
+		}
+	}
+
+	ps := PlayerState{
+		Player:    p.String(),
+		Board:     board,
+		Next:      []PairPayload{},
+		MovesLeft: m.MovesLeft(p),
+		Pending:   m.Pending(p),
+		Score:     m.Score(p),
+		MaxChain:  m.MaxChain(p),
+	}
+	for _, pair := range m.Preview(p) {
+		ps.Next = append(ps.Next, PairPayload{Main: pair.Main.String(), Sub: pair.Sub.String()})
+	}
+	if piece := m.Piece(p); piece != nil {
+		ps.Piece = &PiecePayload{
+			Main:     piece.Pair.Main.String(),
+			Sub:      piece.Pair.Sub.String(),
+			Row:      piece.Row,
+			Col:      piece.Col,
+			Rotation: int(piece.Rot),
+		}
+	}
+	return ps
+}
+
+// ResultMessages turns the outcome of an input into the events to send,
+// always ending with a fresh state snapshot.
+func ResultMessages(m *game.Match, p game.Player, res game.Result) []Message {
+	var msgs []Message
+	for _, step := range res.Chain {
+		msgs = append(msgs, Message{TypeChain, ChainPayload{
+			Player:  p.String(),
+			Chain:   step.Chain,
+			Score:   step.Score,
+			Cleared: step.Cleared,
+			Garbage: step.Garbage,
+		}})
+	}
+	if n := res.Nuisance; n != nil {
+		msgs = append(msgs, Message{TypeNuisance, NuisancePayload{
+			Player:    p.String(),
+			Generated: n.Generated,
+			Offset:    n.Offset,
+			Sent:      n.Sent,
+			Dropped:   n.Dropped,
+			Pending: map[string]int{
+				game.Player1.String(): m.Pending(game.Player1),
+				game.Player2.String(): m.Pending(game.Player2),
+			},
+		}})
+	}
+	if res.TurnChanged {
+		msgs = append(msgs, Message{TypeTurnChange, TurnChangePayload{
+			Turn:      m.Turn().String(),
+			TurnCount: m.TurnCount(),
+			MovesLeft: map[string]int{
+				game.Player1.String(): m.MovesLeft(game.Player1),
+				game.Player2.String(): m.MovesLeft(game.Player2),
+			},
+		}})
+	}
+	if res.GameOver {
+		msgs = append(msgs, Message{TypeGameOver, GameOverPayload{
+			Winner: m.Winner().String(),
+			Reason: lib.MatchEndTopOut,
+		}})
+	}
+	return append(msgs, Message{TypeState, NewState(m)})
+}
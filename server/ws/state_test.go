@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/sodefrin/PP/server/game"
+)
+
+func TestResultMessagesNuisance(t *testing.T) {
+	m := game.NewMatch(game.NewGenerator(1, true))
+	res := game.Result{
+		Locked:      true,
+		Chain:       []game.ChainStep{{Chain: 1, Score: 40}},
+		TurnChanged: true,
+		Nuisance:    &game.NuisanceResult{Generated: 3, Offset: 1, Sent: 2},
+	}
+
+	msgs := ResultMessages(m, game.Player1, res)
+	var types []string
+	for _, msg := range msgs {
+		types = append(types, msg.Type)
+	}
+	want := []string{TypeChain, TypeNuisance, TypeTurnChange, TypeState}
+	if len(types) != len(want) {
+		t.Fatalf("expected %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, types)
+		}
+	}
+
+	payload := msgs[1].Payload.(NuisancePayload)
+	if payload.Player != "p1" || payload.Generated != 3 || payload.Offset != 1 || payload.Sent != 2 {
+		t.Errorf("unexpected nuisance payload: %+v", payload)
+	}
+	if _, ok := payload.Pending["p2"]; !ok {
+		t.Errorf("expected pending for both players, got %v", payload.Pending)
+	}
+}